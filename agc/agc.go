@@ -0,0 +1,244 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package agc implements a streaming automatic gain control for G711 or LPCM audio.
+
+The AGC follows the signal level with an envelope of configurable attack and
+release times and steers the gain so that the envelope reaches a target level.
+Signals below the noise gate freeze the gain, so that silence and background
+noise are not amplified. A-law and u-law data is processed without decoding
+through gain tables in steps of half a decibel.
+
+An AGC can filter frames in place or wrap an io.Reader or io.Writer, such as
+the input or output of a g711 Encoder or Decoder.
+*/
+package agc
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	blockSize = 80 // Samples per level measurement, 10ms
+	fullScale = 32768.0
+	tableStep = 0.5 // Gain table resolution in dB
+)
+
+// Config holds the AGC parameters
+type Config struct {
+	Target  float64 // Target envelope level in dBov
+	Attack  float64 // Envelope rise time constant in ms
+	Release float64 // Envelope fall time constant in ms
+	MaxGain float64 // Largest gain in dB
+	MinGain float64 // Smallest gain in dB, negative for attenuation
+	Gate    float64 // Noise gate level in dBov, the gain holds below it
+}
+
+// DefaultConfig is an AGC for speech
+var DefaultConfig = Config{
+	Target:  -20,
+	Attack:  10,
+	Release: 1000,
+	MaxGain: 20,
+	MinGain: -20,
+	Gate:    -55,
+}
+
+// AGC is an automatic gain control
+type AGC struct {
+	format  int // data format
+	config  Config
+	attack  float64 // envelope rise coefficient per block
+	release float64 // envelope fall coefficient per block
+	gate    float64 // noise gate block power
+	env     float64 // envelope power, 0 until the first block above the gate
+	gain    float64 // current gain in dB
+	k       float64 // current linear gain
+	table   *g711.GainTable
+	tables  map[int]*g711.GainTable // gain tables by step
+	decode  func(uint8) int16
+	acc     float64 // block sum of squares
+	n       int     // block samples
+	samples []int16
+}
+
+// New returns a pointer to an AGC for data in the given format
+func New(format int, c Config) (*AGC, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid format")
+	}
+	if c.Attack <= 0 || c.Release <= 0 || c.MinGain > c.MaxGain {
+		return nil, errors.New("invalid configuration")
+	}
+	blockMs := 1000.0 * blockSize / dsp.SampleRate
+	a := &AGC{
+		format:  format,
+		config:  c,
+		attack:  1 - math.Exp(-blockMs/c.Attack),
+		release: 1 - math.Exp(-blockMs/c.Release),
+		gate:    fullScale * fullScale * dsp.DB(c.Gate),
+		k:       1,
+		tables:  make(map[int]*g711.GainTable),
+	}
+	switch format {
+	case g711.Alaw:
+		a.decode = g711.DecodeAlawFrame
+	case g711.Ulaw:
+		a.decode = g711.DecodeUlawFrame
+	}
+	return a, nil
+}
+
+// Gain returns the current gain in dB
+func (a *AGC) Gain() float64 {
+	return a.gain
+}
+
+// Process applies the AGC in place to a frame of audio data.
+// LPCM frames must hold whole samples.
+func (a *AGC) Process(frame []byte) error {
+	if a.format == g711.Lpcm {
+		if len(frame)%2 != 0 {
+			return errors.New("odd LPCM frame length")
+		}
+		a.samples = pcm.Decode(a.format, frame, a.samples[:0])
+		a.ProcessSamples(a.samples)
+		pcm.Encode(a.format, a.samples, frame[:0])
+		return nil
+	}
+	for i, b := range frame {
+		x := float64(a.decode(b))
+		if a.table != nil {
+			frame[i] = a.table.ApplyGainFrame(b)
+		}
+		a.measure(x)
+	}
+	return nil
+}
+
+// ProcessSamples applies the AGC in place to 16bit linear samples
+func (a *AGC) ProcessSamples(s []int16) {
+	for i, x := range s {
+		s[i] = clip(float64(x) * a.k)
+		a.measure(float64(x))
+	}
+}
+
+// measure adds an input sample to the level measurement and updates the
+// gain at the end of each block
+func (a *AGC) measure(x float64) {
+	a.acc += x * x
+	if a.n++; a.n < blockSize {
+		return
+	}
+	p := a.acc / blockSize
+	a.acc, a.n = 0, 0
+	if p < a.gate {
+		return
+	}
+	switch {
+	case a.env == 0:
+		a.env = p
+	case p > a.env:
+		a.env += (p - a.env) * a.attack
+	default:
+		a.env += (p - a.env) * a.release
+	}
+	level := 10 * math.Log10(a.env/(fullScale*fullScale))
+	a.setGain(math.Max(a.config.MinGain, math.Min(a.config.MaxGain, a.config.Target-level)))
+}
+
+// setGain sets the gain in dB
+func (a *AGC) setGain(dB float64) {
+	if a.decode == nil {
+		a.gain, a.k = dB, math.Pow(10, dB/20)
+		return
+	}
+	step := int(math.Round(dB / tableStep))
+	a.gain = float64(step) * tableStep
+	if step == 0 {
+		a.table = nil
+		return
+	}
+	t, ok := a.tables[step]
+	if !ok {
+		t, _ = g711.Gain(a.format, a.gain)
+		a.tables[step] = t
+	}
+	a.table = t
+}
+
+// Reset discards the AGC state. This permits reusing an AGC rather than allocating a new one.
+func (a *AGC) Reset() {
+	a.env, a.acc, a.n = 0, 0, 0
+	a.setGain(0)
+}
+
+func clip(x float64) int16 {
+	x = math.Round(x)
+	if x > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(x)
+}
+
+// Reader applies an AGC to the data read from an io.Reader
+type Reader struct {
+	source *pcm.ProcessReader
+}
+
+// NewReader returns a pointer to a Reader that applies the AGC to data read from r,
+// in the format of the AGC
+func NewReader(r io.Reader, a *AGC) (*Reader, error) {
+	if r == nil {
+		return nil, errors.New("io.Reader is nil")
+	}
+	if a == nil {
+		return nil, errors.New("AGC is nil")
+	}
+	return &Reader{pcm.NewProcessReader(r, a.format, a.Process)}, nil
+}
+
+// Read reads data from the source and applies the AGC to it. It returns
+// the number of bytes read and any error encountered.
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.source.Read(p)
+}
+
+// Writer applies an AGC to the data written to an io.Writer
+type Writer struct {
+	dest *pcm.ProcessWriter
+}
+
+// NewWriter returns a pointer to a Writer that applies the AGC to data written to w,
+// in the format of the AGC
+func NewWriter(w io.Writer, a *AGC) (*Writer, error) {
+	if w == nil {
+		return nil, errors.New("io.Writer is nil")
+	}
+	if a == nil {
+		return nil, errors.New("AGC is nil")
+	}
+	return &Writer{pcm.NewProcessWriter(w, a.format, a.Process)}, nil
+}
+
+// Write applies the AGC to the contents of p and writes them to the destination.
+// It returns the number of bytes written and any error encountered.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.dest.Write(p)
+}
@@ -0,0 +1,116 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package agc
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// sine returns ms milliseconds of a 1kHz sine wave at level dBov
+func sine(level float64, ms int) []int16 {
+	a := math.Sqrt2 * fullScale * math.Pow(10, level/20)
+	s := make([]int16, ms*8)
+	for i := range s {
+		s[i] = int16(math.Round(a * math.Sin(2*math.Pi*1000*float64(i)/8000)))
+	}
+	return s
+}
+
+// level returns the level of samples in dBov
+func level(s []int16) float64 {
+	var p float64
+	for _, x := range s {
+		p += float64(x) * float64(x)
+	}
+	return 10 * math.Log10(p/float64(len(s))/(fullScale*fullScale))
+}
+
+// Test the convergence of the gain in all formats
+func TestAGC(t *testing.T) {
+	var tests = []struct {
+		name  string
+		level float64
+		ms    int
+		want  float64
+	}{
+		{"quiet", -35, 5000, DefaultConfig.Target},
+		{"loud", -5, 200, DefaultConfig.Target},
+		{"below max gain", -45, 5000, -45 + DefaultConfig.MaxGain},
+		{"below gate", -60, 2000, -60},
+	}
+	for _, tc := range tests {
+		for _, format := range []int{g711.Lpcm, g711.Alaw, g711.Ulaw} {
+			a, err := New(format, DefaultConfig)
+			if err != nil {
+				t.Fatalf("Failed to create AGC: %s\n", err)
+			}
+			data := pcm.Encode(format, sine(tc.level, tc.ms), nil)
+			if err := a.Process(data); err != nil {
+				t.Fatalf("Process failed: %s\n", err)
+			}
+			s := pcm.Decode(format, data, nil)
+			if l := level(s[len(s)-800:]); math.Abs(l-tc.want) > 1 {
+				t.Errorf("%s format %d: output level %.2f dBov, expected %.2f", tc.name, format, l, tc.want)
+			}
+		}
+	}
+	if _, err := New(g711.Alaw, Config{Attack: 10, Release: 0}); err == nil {
+		t.Error("Invalid configuration accepted")
+	}
+}
+
+// Test that the Reader and Writer match Process over odd LPCM chunks
+func TestReaderWriter(t *testing.T) {
+	data := pcm.Encode(g711.Lpcm, sine(-40, 1000), nil)
+	want := append([]byte{}, data...)
+	a, _ := New(g711.Lpcm, DefaultConfig)
+	a.Process(want)
+
+	a, _ = New(g711.Lpcm, DefaultConfig)
+	var out bytes.Buffer
+	w, err := NewWriter(&out, a)
+	if err != nil {
+		t.Fatalf("Failed to create Writer: %s\n", err)
+	}
+	for chunk := data; len(chunk) > 0; {
+		n := 333
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+		w.Write(chunk[:n])
+		chunk = chunk[n:]
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Error("Writer output differs from Process")
+	}
+
+	a, _ = New(g711.Lpcm, DefaultConfig)
+	r, err := NewReader(bytes.NewReader(data), a)
+	if err != nil {
+		t.Fatalf("Failed to create Reader: %s\n", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Reader output differs from Process: %v", err)
+	}
+
+	a, _ = New(g711.Lpcm, DefaultConfig)
+	r, _ = NewReader(iotest.OneByteReader(bytes.NewReader(data)), a)
+	got, err = io.ReadAll(iotest.OneByteReader(r))
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("One byte Reader output differs from Process: %v", err)
+	}
+}
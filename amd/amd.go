@@ -0,0 +1,208 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package amd implements answering machine detection on G711 or LPCM audio,
+telling a live person from a recorded greeting at the start of an answered call.
+
+The analysis follows the heuristics and thresholds of the Asterisk AMD()
+application: a person usually answers within a short initial silence with a
+brief greeting of a word or two and then waits for the caller, while a machine
+plays a long greeting of many words. The verdict comes with the cause that
+decided it, in the format of the AMDCAUSE Asterisk variable.
+
+Once a machine answers, the BeepDetector finds the end of the beep that
+follows the greeting, the time to start playing a message.
+*/
+package amd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// Verdict is the outcome of the analysis
+type Verdict int
+
+const (
+	// Verdicts
+	NotSure Verdict = iota // No decision within the analysis time
+	Human                  // A live person answered
+	Machine                // An answering machine answered
+)
+
+const (
+	blockSize = 160 // Samples per analysed frame, 20ms
+	ms        = dsp.SampleRate / 1000
+)
+
+var names = [...]string{"NOTSURE", "HUMAN", "MACHINE"}
+
+func (v Verdict) String() string {
+	if v < 0 || int(v) >= len(names) {
+		return "unknown"
+	}
+	return names[v]
+}
+
+// Config holds the analysis thresholds, durations in ms
+type Config struct {
+	InitialSilence       int // Longest silence before the greeting
+	Greeting             int // Longest greeting of a person
+	AfterGreetingSilence int // Silence after the greeting that signals a person
+	TotalAnalysisTime    int // Longest analysis before NOTSURE
+	MinWordLength        int // Shortest voice that counts as a word
+	BetweenWordsSilence  int // Shortest silence that separates words
+	MaxWords             int // Words that signal a machine
+	MaxWordLength        int // Longest single word of a person
+	SilenceThreshold     int // Highest mean absolute amplitude of a silent frame
+}
+
+// DefaultConfig holds the Asterisk AMD() defaults
+var DefaultConfig = Config{
+	InitialSilence:       2500,
+	Greeting:             1500,
+	AfterGreetingSilence: 800,
+	TotalAnalysisTime:    5000,
+	MinWordLength:        100,
+	BetweenWordsSilence:  50,
+	MaxWords:             2,
+	MaxWordLength:        5000,
+	SilenceThreshold:     256,
+}
+
+// Result is the outcome of the analysis
+type Result struct {
+	Verdict Verdict
+	Cause   string // Deciding rule and its values, such as "MAXWORDS-2-2"
+	Offset  int64  // Sample offset of the decision
+}
+
+// Detector analyses the start of a call
+type Detector struct {
+	format     int // input format
+	config     Config
+	framer     dsp.Framer
+	samples    []int16 // decoding buffer
+	offset     int64   // sample offset of the next frame
+	silence    int     // current silence in ms
+	voice      int     // total voice in ms
+	word       int     // current voice in ms
+	words      int     // words counted
+	inSilence  bool    // the last frames were silence between words
+	initial    bool    // no voice yet
+	inGreeting bool    // the greeting started
+	decided    bool
+	result     Result
+}
+
+// New returns a pointer to a Detector for data in the given format
+func New(format int, c Config) (*Detector, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	if c.TotalAnalysisTime <= 0 || c.MaxWords < 1 || c.MinWordLength <= 0 {
+		return nil, errors.New("invalid configuration")
+	}
+	d := &Detector{format: format, config: c, framer: dsp.Framer{Size: blockSize}}
+	d.Reset()
+	return d, nil
+}
+
+// Process analyses a frame of audio data. It returns the result and true once
+// the analysis is decided, and keeps returning it for the following frames.
+func (d *Detector) Process(frame []byte) (Result, bool) {
+	d.samples = pcm.Decode(d.format, frame, d.samples[:0])
+	return d.ProcessSamples(d.samples)
+}
+
+// ProcessSamples analyses 16bit linear samples. It returns the result and true
+// once the analysis is decided, and keeps returning it for the following samples.
+func (d *Detector) ProcessSamples(s []int16) (Result, bool) {
+	if !d.decided {
+		d.framer.Push(s, d.block)
+	}
+	return d.result, d.decided
+}
+
+// Flush ends the stream, such as on a hangup, and returns the result.
+// An undecided analysis is NOTSURE.
+func (d *Detector) Flush() Result {
+	if !d.decided {
+		d.decide(NotSure, fmt.Sprintf("HANGUP-%d", d.offset/ms))
+	}
+	return d.result
+}
+
+// Reset discards the Detector state. This permits reusing a Detector rather than allocating a new one.
+func (d *Detector) Reset() {
+	d.framer.Reset()
+	d.offset, d.silence, d.voice, d.word, d.words = 0, 0, 0, 0, 0
+	d.inSilence, d.initial, d.inGreeting = false, true, false
+	d.decided, d.result = false, Result{}
+}
+
+// block analyses a single frame
+func (d *Detector) block(s []float64) {
+	if d.decided {
+		return
+	}
+	const frameMs = blockSize / ms
+	c := d.config
+	d.offset += blockSize
+	if d.offset/ms >= int64(c.TotalAnalysisTime) {
+		d.decide(NotSure, fmt.Sprintf("TOOLONG-%d", d.offset/ms))
+		return
+	}
+	var sum float64
+	for _, x := range s {
+		sum += math.Abs(x)
+	}
+	if sum/blockSize < float64(c.SilenceThreshold) {
+		d.silence += frameMs
+		if d.silence >= c.BetweenWordsSilence {
+			d.inSilence, d.word = true, 0
+		}
+		if d.initial && d.silence >= c.InitialSilence {
+			d.decide(Machine, fmt.Sprintf("INITIALSILENCE-%d-%d", d.silence, c.InitialSilence))
+			return
+		}
+		if d.inGreeting && d.silence >= c.AfterGreetingSilence {
+			d.decide(Human, fmt.Sprintf("HUMAN-%d-%d", d.silence, c.AfterGreetingSilence))
+		}
+		return
+	}
+	d.silence = 0
+	d.word += frameMs
+	d.voice += frameMs
+	// A word is counted when enough voice follows a silence
+	if d.word >= c.MinWordLength && d.inSilence {
+		d.words++
+		d.inSilence = false
+	}
+	switch {
+	case d.word >= c.MaxWordLength:
+		d.decide(Machine, fmt.Sprintf("MAXWORDLENGTH-%d", d.word))
+	case d.words >= c.MaxWords:
+		d.decide(Machine, fmt.Sprintf("MAXWORDS-%d-%d", d.words, c.MaxWords))
+	case d.inGreeting && d.voice >= c.Greeting:
+		d.decide(Machine, fmt.Sprintf("LONGGREETING-%d-%d", d.voice, c.Greeting))
+	case d.voice >= c.MinWordLength:
+		d.initial, d.inGreeting = false, true
+	}
+}
+
+// decide ends the analysis
+func (d *Detector) decide(v Verdict, cause string) {
+	d.decided = true
+	d.result = Result{Verdict: v, Cause: cause, Offset: d.offset}
+}
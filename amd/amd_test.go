@@ -0,0 +1,98 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package amd
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/generator"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// voice returns ms milliseconds of noise at a speech level
+func voice(ms int) generator.Signal {
+	return generator.WhiteNoise(-20, ms, int64(ms))
+}
+
+// detect runs a new Detector over data in chunks of 160 bytes
+func detect(t *testing.T, c Config, data []byte, format int) Result {
+	d, err := New(format, c)
+	if err != nil {
+		t.Fatalf("Failed to create Detector: %s\n", err)
+	}
+	for len(data) > 0 {
+		n := 160
+		if n > len(data) {
+			n = len(data)
+		}
+		if r, ok := d.Process(data[:n]); ok {
+			return r
+		}
+		data = data[n:]
+	}
+	return d.Flush()
+}
+
+// Test the verdicts and causes of the analysis rules
+func TestAMD(t *testing.T) {
+	long := DefaultConfig
+	long.Greeting, long.MaxWordLength = 10000, 1000
+	many := DefaultConfig
+	many.Greeting, many.MaxWords, many.AfterGreetingSilence = 10000, 100, 1000
+	var words []generator.Signal
+	for i := 0; i < 10; i++ {
+		words = append(words, voice(300), generator.Silence(300))
+	}
+	var tests = []struct {
+		name    string
+		config  Config
+		signal  generator.Signal
+		verdict Verdict
+		cause   string
+	}{
+		{"hello", DefaultConfig, generator.Sequence(generator.Silence(300), voice(600), generator.Silence(1000)), Human, "HUMAN-800-800"},
+		{"initial silence", DefaultConfig, generator.Silence(3000), Machine, "INITIALSILENCE-2500-2500"},
+		{"long greeting", DefaultConfig, generator.Sequence(generator.Silence(300), voice(2000)), Machine, "LONGGREETING-1500-1500"},
+		{"two words", DefaultConfig, generator.Sequence(generator.Silence(300), voice(400), generator.Silence(200), voice(400)), Machine, "MAXWORDS-2-2"},
+		{"long word", long, generator.Sequence(generator.Silence(300), voice(2000)), Machine, "MAXWORDLENGTH-1000"},
+		{"too long", many, generator.Sequence(words...), NotSure, "TOOLONG-5000"},
+		{"hangup", DefaultConfig, generator.Sequence(generator.Silence(300), voice(300)), NotSure, "HANGUP-600"},
+	}
+	for _, tc := range tests {
+		r, _ := generator.NewReader(tc.signal, g711.Lpcm)
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Reading failed: %s\n", err)
+		}
+		samples := pcm.Decode(g711.Lpcm, data, nil)
+		for _, format := range []int{g711.Alaw, g711.Ulaw, g711.Lpcm} {
+			res := detect(t, tc.config, pcm.Encode(format, samples, nil), format)
+			if res.Verdict != tc.verdict || res.Cause != tc.cause {
+				t.Errorf("%s format %d: %s %s, expected %s %s", tc.name, format, res.Verdict, res.Cause, tc.verdict, tc.cause)
+			}
+		}
+	}
+	if _, err := New(g711.Alaw, Config{}); err == nil {
+		t.Error("Invalid configuration accepted")
+	}
+}
+
+// Test that continuous recorded speech is a machine
+func TestSpeech(t *testing.T) {
+	data, err := os.ReadFile("../testing/speech.ulaw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	if res := detect(t, DefaultConfig, data, g711.Ulaw); res.Verdict != Machine {
+		t.Errorf("Speech: %s %s, expected MACHINE", res.Verdict, res.Cause)
+	}
+}
@@ -0,0 +1,259 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package amd
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	beepWindow = 128 // Samples per spectrum, 16ms with 62.5Hz bins
+	beepHop    = 64  // Samples between spectra, 8ms
+	peakBins   = 2   // Bins on each side of the peak that hold the tone energy
+	purity     = 0.8 // Least fraction of the window energy in the peak
+)
+
+// BeepConfig holds the beep detection parameters
+type BeepConfig struct {
+	MinFreq      float64 // Lowest beep frequency in Hz
+	MaxFreq      float64 // Highest beep frequency in Hz
+	Tolerance    float64 // Largest relative deviation from the mean beep frequency
+	MinLevel     float64 // Lowest beep level in dBm0
+	MinTone      int     // Shortest beep in ms
+	MaxTone      int     // Longest beep in ms
+	Silence      int     // Silence after the beep in ms
+	SilenceLevel float64 // Highest level of silence in dBm0
+}
+
+// DefaultBeepConfig finds the beeps of common voicemail systems
+var DefaultBeepConfig = BeepConfig{
+	MinFreq:      400,
+	MaxFreq:      2000,
+	Tolerance:    0.05,
+	MinLevel:     -40,
+	MinTone:      100,
+	MaxTone:      1500,
+	Silence:      100,
+	SilenceLevel: -45,
+}
+
+// Beep is a detected beep
+type Beep struct {
+	Freq  float64 // Mean frequency in Hz
+	Start int64   // Sample offset of the start of the beep
+	End   int64   // Sample offset of the end of the beep
+}
+
+// BeepDetector states
+const (
+	idle      = iota // waiting for a tone
+	inTone           // a tone is in progress
+	tooLong          // a tone too long for a beep is in progress
+	afterTone        // waiting for silence after a tone
+)
+
+// BeepDetector finds a steady single tone followed by silence, such as the beep
+// at the end of a voicemail greeting
+type BeepDetector struct {
+	format    int // input format
+	config    BeepConfig
+	hann      []float64
+	spec      []complex128
+	window    []float64 // last beepWindow samples
+	framer    dsp.Framer
+	samples   []int16 // decoding buffer
+	offset    int64   // sample offset of the end of the window
+	state     int
+	sum       float64 // sum of the tone window frequencies
+	count     int     // tone windows
+	threshold float64 // amplitude that marks the tone edges
+	start     int64   // start of the tone
+	end       int64   // end of the tone
+	quiet     int64   // silent samples after the tone
+	beeps     []Beep
+}
+
+// NewBeepDetector returns a pointer to a BeepDetector for data in the given format
+func NewBeepDetector(format int, c BeepConfig) (*BeepDetector, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	if c.MinFreq <= 0 || c.MaxFreq <= c.MinFreq || c.MaxFreq >= dsp.SampleRate/2 || c.MinTone <= 0 || c.MaxTone < c.MinTone {
+		return nil, errors.New("invalid configuration")
+	}
+	b := &BeepDetector{
+		format: format,
+		config: c,
+		hann:   make([]float64, beepWindow),
+		spec:   make([]complex128, beepWindow),
+		window: make([]float64, beepWindow),
+		framer: dsp.Framer{Size: beepHop},
+	}
+	for i := range b.hann {
+		b.hann[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/beepWindow)
+	}
+	return b, nil
+}
+
+// Process finds beeps in a frame of audio data and returns the beeps
+// confirmed by silence within it.
+func (b *BeepDetector) Process(frame []byte) []Beep {
+	b.samples = pcm.Decode(b.format, frame, b.samples[:0])
+	return b.ProcessSamples(b.samples)
+}
+
+// ProcessSamples finds beeps in 16bit linear samples and returns the beeps
+// confirmed by silence within them.
+func (b *BeepDetector) ProcessSamples(s []int16) []Beep {
+	b.beeps = b.beeps[:0]
+	b.framer.Push(s, b.block)
+	return b.beeps
+}
+
+// Flush ends the stream and returns a beep that was followed by silence up to the end
+func (b *BeepDetector) Flush() []Beep {
+	b.beeps = b.beeps[:0]
+	if b.state == afterTone && b.quiet > 0 {
+		b.report()
+	}
+	b.state = idle
+	return b.beeps
+}
+
+// Reset discards the BeepDetector state. This permits reusing a BeepDetector rather than allocating a new one.
+func (b *BeepDetector) Reset() {
+	b.framer.Reset()
+	for i := range b.window {
+		b.window[i] = 0
+	}
+	b.offset, b.state = 0, idle
+}
+
+// block slides the window by one hop and classifies it
+func (b *BeepDetector) block(s []float64) {
+	copy(b.window, b.window[beepHop:])
+	copy(b.window[beepWindow-beepHop:], s)
+	b.offset += beepHop
+	if b.offset < beepWindow {
+		return
+	}
+	c := b.config
+	freq, energy := b.analyse()
+	level := dsp.Level(energy / beepWindow)
+	steady := freq > 0 && level >= c.MinLevel
+	if steady && (b.state == inTone || b.state == tooLong) {
+		mean := b.sum / float64(b.count)
+		steady = math.Abs(freq-mean) <= c.Tolerance*mean
+	}
+	switch b.state {
+	case inTone:
+		if steady {
+			b.sum += freq
+			b.count++
+			b.edges(energy)
+			if b.end-b.start > int64(c.MaxTone)*ms {
+				b.state = tooLong
+			}
+			return
+		}
+		if b.end-b.start < int64(c.MinTone)*ms {
+			b.state = idle
+			break
+		}
+		b.state, b.quiet = afterTone, 0
+		fallthrough
+	case afterTone:
+		switch {
+		case dsp.Level(dsp.Energy(s)/beepHop) <= c.SilenceLevel:
+			b.quiet += beepHop
+		case b.offset-b.end > beepHop:
+			// Sound after the tone, it was not a beep
+			b.state = idle
+		}
+		if b.state == afterTone && b.quiet >= int64(c.Silence)*ms {
+			b.report()
+			b.state = idle
+		}
+		return
+	case tooLong:
+		if steady {
+			return
+		}
+		b.state = idle
+	}
+	if steady && freq >= c.MinFreq && freq <= c.MaxFreq {
+		b.state = inTone
+		b.sum, b.count, b.threshold = freq, 1, 0
+		b.start = -1
+		b.edges(energy)
+	}
+}
+
+// edges updates the start and end of the tone from the samples of the window
+// above a quarter of the tone amplitude
+func (b *BeepDetector) edges(energy float64) {
+	b.threshold = math.Max(b.threshold, math.Sqrt(2*energy/beepWindow)/4)
+	first := b.offset - beepWindow
+	for i, x := range b.window {
+		if math.Abs(x) < b.threshold {
+			continue
+		}
+		if b.start < 0 {
+			b.start = first + int64(i)
+		}
+		b.end = first + int64(i) + 1
+	}
+}
+
+// analyse returns the frequency of the window peak, 0 if the window is not a
+// single tone, and the window energy
+func (b *BeepDetector) analyse() (float64, float64) {
+	energy := dsp.Energy(b.window)
+	for i, x := range b.window {
+		b.spec[i] = complex(x*b.hann[i], 0)
+	}
+	dsp.FFT(b.spec)
+	var mag [beepWindow/2 + 1]float64
+	var total float64
+	peak := 1
+	for k := 1; k <= beepWindow/2; k++ {
+		mag[k] = cmplx.Abs(b.spec[k])
+		total += mag[k] * mag[k]
+		if mag[k] > mag[peak] {
+			peak = k
+		}
+	}
+	if total == 0 || peak < peakBins || peak > beepWindow/2-peakBins {
+		return 0, energy
+	}
+	var tone float64
+	for k := peak - peakBins; k <= peak+peakBins; k++ {
+		tone += mag[k] * mag[k]
+	}
+	if tone < purity*total {
+		return 0, energy
+	}
+	// Parabolic interpolation of the peak on the log magnitudes
+	l, m, r := math.Log(mag[peak-1]+1e-9), math.Log(mag[peak]+1e-9), math.Log(mag[peak+1]+1e-9)
+	delta := 0.0
+	if d := l - 2*m + r; d != 0 {
+		delta = 0.5 * (l - r) / d
+	}
+	return (float64(peak) + delta) * dsp.SampleRate / beepWindow, energy
+}
+
+// report adds the ended tone as a beep
+func (b *BeepDetector) report() {
+	b.beeps = append(b.beeps, Beep{Freq: b.sum / float64(b.count), Start: b.start, End: b.end})
+}
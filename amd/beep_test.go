@@ -0,0 +1,131 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package amd
+
+import (
+	"io"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/generator"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// render returns the samples of a signal
+func render(t *testing.T, s generator.Signal) []int16 {
+	r, _ := generator.NewReader(s, g711.Lpcm)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Reading failed: %s\n", err)
+	}
+	return pcm.Decode(g711.Lpcm, data, nil)
+}
+
+// warble returns ms milliseconds of a tone at -10dBm0 whose frequency varies by
+// depth around freq at 5Hz
+func warble(freq, depth float64, ms int) []int16 {
+	s := make([]int16, ms*8)
+	a := dsp.Amplitude(-10)
+	var phase float64
+	for i := range s {
+		f := freq * (1 + depth*math.Sin(2*math.Pi*5*float64(i)/8000))
+		phase += 2 * math.Pi * f / 8000
+		s[i] = int16(math.Round(a * math.Sin(phase)))
+	}
+	return s
+}
+
+// detectBeeps runs a new BeepDetector over samples encoded in format, in chunks of 160 bytes
+func detectBeeps(t *testing.T, s []int16, format int) []Beep {
+	b, err := NewBeepDetector(format, DefaultBeepConfig)
+	if err != nil {
+		t.Fatalf("Failed to create BeepDetector: %s\n", err)
+	}
+	data := pcm.Encode(format, s, nil)
+	var beeps []Beep
+	for len(data) > 0 {
+		n := 160
+		if n > len(data) {
+			n = len(data)
+		}
+		beeps = append(beeps, b.Process(data[:n])...)
+		data = data[n:]
+	}
+	return append(beeps, b.Flush()...)
+}
+
+// Test beeps of several frequencies and durations after a greeting
+func TestBeep(t *testing.T) {
+	greeting := render(t, generator.Sequence(voice(1500), generator.Silence(300)))
+	var tests = []struct {
+		freq  float64
+		depth float64
+		ms    int
+	}{
+		{440, 0, 150},
+		{1000, 0, 500},
+		{1400, 0.02, 1200},
+		{1950, 0, 300},
+		{850, 0.03, 700},
+	}
+	for _, tc := range tests {
+		s := append(append([]int16{}, greeting...), warble(tc.freq, tc.depth, tc.ms)...)
+		end := int64(len(s))
+		s = append(s, make([]int16, 4000)...)
+		for _, format := range []int{g711.Alaw, g711.Ulaw, g711.Lpcm} {
+			beeps := detectBeeps(t, s, format)
+			if len(beeps) != 1 {
+				t.Errorf("%.0fHz %dms format %d: unexpected beeps: %v", tc.freq, tc.ms, format, beeps)
+				continue
+			}
+			b := beeps[0]
+			if math.Abs(b.Freq-tc.freq) > 0.02*tc.freq || abs(b.End-end) > 16 || abs(b.Start-int64(len(greeting))) > 16 {
+				t.Errorf("%.0fHz %dms format %d: beep %.1fHz %d-%d, expected %d-%d", tc.freq, tc.ms, format, b.Freq, b.Start, b.End, len(greeting), end)
+			}
+		}
+	}
+}
+
+// Test signals that are not beeps
+func TestNoBeep(t *testing.T) {
+	dtmf, _ := generator.DTMF("5", -10, 300, 500)
+	var tests = []struct {
+		name string
+		s    []int16
+	}{
+		{"long tone", append(warble(1000, 0, 2000), make([]int16, 4000)...)},
+		{"short tone", append(warble(1000, 0, 60), make([]int16, 4000)...)},
+		{"low tone", append(warble(300, 0, 500), make([]int16, 4000)...)},
+		{"tone and voice", append(warble(1000, 0, 500), render(t, voice(1000))...)},
+		{"dual tone", render(t, dtmf)},
+		{"noise", render(t, generator.Sequence(voice(500), generator.Silence(500)))},
+	}
+	for _, tc := range tests {
+		if beeps := detectBeeps(t, tc.s, g711.Ulaw); len(beeps) != 0 {
+			t.Errorf("%s: unexpected beeps: %v", tc.name, beeps)
+		}
+	}
+	data, err := os.ReadFile("../testing/speech.alaw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	if beeps := detectBeeps(t, pcm.Decode(g711.Alaw, data, nil), g711.Alaw); len(beeps) != 0 {
+		t.Errorf("Speech: unexpected beeps: %v", beeps)
+	}
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
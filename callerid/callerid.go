@@ -0,0 +1,292 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package callerid encodes and decodes on-hook Caller ID in G711 or LPCM audio.
+It supports FSK data transmission with Bell 202 (Bellcore GR-30) or V.23
+(ETSI EN 300 659) modulation, in single (SDMF) or multiple (MDMF) data message
+format, and DTMF Caller ID (ETSI ES 200 778).
+*/
+package callerid
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/zaf/g711/generator"
+)
+
+// Modulation is the Caller ID transmission method
+type Modulation int
+
+const (
+	// Bell202 is 1200 baud FSK with 1200Hz mark and 2200Hz space
+	Bell202 Modulation = iota
+	// V23 is 1200 baud FSK with 1300Hz mark and 2100Hz space
+	V23
+	// DTMF sends the number as DTMF digits
+	DTMF
+)
+
+// Reasons for the absence of the number or name
+const (
+	Unavailable byte = 'O' // Out of area or unavailable
+	Private     byte = 'P' // Withheld by the caller
+)
+
+const (
+	baud        = 1200
+	sdmfType    = 0x04 // Single data message
+	mdmfType    = 0x80 // Multiple data message
+	paramTime   = 0x01 // Date and time, MMDDHHMM
+	paramNumber = 0x02 // Calling line number
+	paramNoNum  = 0x04 // Reason for absence of the number
+	paramName   = 0x07 // Calling party name
+	paramNoName = 0x08 // Reason for absence of the name
+	maxNumber   = 20   // Longest number in MDMF
+	maxName     = 50   // Longest name in MDMF
+	dtmfTone    = 70   // DTMF digit duration in ms
+	dtmfPause   = 70   // DTMF pause duration in ms
+)
+
+// Config holds the parameters of the Caller ID transmission
+type Config struct {
+	Modulation Modulation
+	SDMF       bool    // Use the single data message format, FSK only
+	Level      float64 // Sending level in dBm0, per frequency for DTMF
+	Seizure    int     // Channel seizure bits of alternating space and mark, FSK only
+	Marks      int     // Mark bits before the message, FSK only
+}
+
+var (
+	// Bell202Config is the Bellcore GR-30 on-hook transmission
+	Bell202Config = Config{Modulation: Bell202, Level: -13, Seizure: 300, Marks: 180}
+	// V23Config is the ETSI EN 300 659-1 on-hook transmission
+	V23Config = Config{Modulation: V23, Level: -13, Seizure: 300, Marks: 80}
+	// DTMFConfig is the ETSI ES 200 778-1 DTMF transmission
+	DTMFConfig = Config{Modulation: DTMF, Level: -7}
+)
+
+// Message is the Caller ID information of a call
+type Message struct {
+	Time         time.Time // Month, day, hour and minute of the call, zero if absent
+	Number       string    // Calling line number
+	NumberReason byte      // Unavailable or Private if the number is absent, 0 otherwise
+	Name         string    // Calling party name, MDMF only
+	NameReason   byte      // Unavailable or Private if the name is absent, 0 otherwise
+}
+
+// frequencies returns the mark and space frequencies of an FSK modulation
+func frequencies(m Modulation) (mark, space float64, err error) {
+	switch m {
+	case Bell202:
+		return 1200, 2200, nil
+	case V23:
+		return 1300, 2100, nil
+	}
+	return 0, 0, errors.New("invalid modulation")
+}
+
+// MarshalSDMF returns the single data message format encoding of a message,
+// including the message type, length and checksum
+func (m *Message) MarshalSDMF() ([]byte, error) {
+	if m.Time.IsZero() {
+		return nil, errors.New("time is required in SDMF")
+	}
+	number := m.Number
+	if number == "" {
+		if m.NumberReason == 0 {
+			return nil, errors.New("number or reason is required")
+		}
+		number = string(m.NumberReason)
+	}
+	if len(number) > 10 {
+		return nil, errors.New("number too long for SDMF")
+	}
+	return frame(sdmfType, append([]byte(timestamp(m.Time)), number...)), nil
+}
+
+// MarshalMDMF returns the multiple data message format encoding of a message,
+// including the message type, length and checksum
+func (m *Message) MarshalMDMF() ([]byte, error) {
+	if len(m.Number) > maxNumber || len(m.Name) > maxName {
+		return nil, errors.New("number or name too long")
+	}
+	var b []byte
+	param := func(t byte, v string) {
+		b = append(append(b, t, byte(len(v))), v...)
+	}
+	if !m.Time.IsZero() {
+		param(paramTime, timestamp(m.Time))
+	}
+	switch {
+	case m.Number != "":
+		param(paramNumber, m.Number)
+	case m.NumberReason != 0:
+		param(paramNoNum, string(m.NumberReason))
+	}
+	switch {
+	case m.Name != "":
+		param(paramName, m.Name)
+	case m.NameReason != 0:
+		param(paramNoName, string(m.NameReason))
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty message")
+	}
+	return frame(mdmfType, b), nil
+}
+
+// Unmarshal parses an SDMF or MDMF message including its type, length and checksum
+func (m *Message) Unmarshal(data []byte) error {
+	if len(data) < 3 || len(data) != int(data[1])+3 {
+		return errors.New("invalid message length")
+	}
+	if checksum(data[:len(data)-1]) != data[len(data)-1] {
+		return errors.New("checksum mismatch")
+	}
+	*m = Message{}
+	body := data[2 : len(data)-1]
+	switch data[0] {
+	case sdmfType:
+		if len(body) < 8 {
+			return errors.New("invalid SDMF message")
+		}
+		if err := m.parseTime(body[:8]); err != nil {
+			return err
+		}
+		m.setNumber(string(body[8:]))
+		return nil
+	case mdmfType:
+		for len(body) > 0 {
+			if len(body) < 2 || len(body) < int(body[1])+2 {
+				return errors.New("invalid MDMF parameter length")
+			}
+			v := body[2 : body[1]+2]
+			switch body[0] {
+			case paramTime:
+				if err := m.parseTime(v); err != nil {
+					return err
+				}
+			case paramNumber:
+				m.Number = string(v)
+			case paramNoNum:
+				if len(v) > 0 {
+					m.NumberReason = v[0]
+				}
+			case paramName:
+				m.Name = string(v)
+			case paramNoName:
+				if len(v) > 0 {
+					m.NameReason = v[0]
+				}
+			}
+			body = body[body[1]+2:]
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown message type 0x%02x", data[0])
+}
+
+// setNumber sets the number or the reason for its absence from an SDMF number field
+func (m *Message) setNumber(n string) {
+	if len(n) == 1 && (n[0] == Unavailable || n[0] == Private) {
+		m.NumberReason = n[0]
+		return
+	}
+	m.Number = n
+}
+
+// parseTime parses an MMDDHHMM field. The year is not transmitted and is left at 0.
+func (m *Message) parseTime(b []byte) error {
+	var month, day, hour, min int
+	if len(b) != 8 {
+		return errors.New("invalid time")
+	}
+	if _, err := fmt.Sscanf(string(b), "%2d%2d%2d%2d", &month, &day, &hour, &min); err != nil {
+		return errors.New("invalid time")
+	}
+	m.Time = time.Date(0, time.Month(month), day, hour, min, 0, 0, time.UTC)
+	return nil
+}
+
+func timestamp(t time.Time) string {
+	return fmt.Sprintf("%02d%02d%02d%02d", int(t.Month()), t.Day(), t.Hour(), t.Minute())
+}
+
+// frame adds the message type, length and checksum to a message body
+func frame(t byte, body []byte) []byte {
+	b := append([]byte{t, byte(len(body))}, body...)
+	return append(b, checksum(b))
+}
+
+// checksum returns the two's complement of the modulo 256 sum of b
+func checksum(b []byte) byte {
+	var s byte
+	for _, c := range b {
+		s += c
+	}
+	return -s
+}
+
+// Signal returns the Caller ID signal of a message
+func Signal(m *Message, c Config) (generator.Signal, error) {
+	if c.Modulation == DTMF {
+		return generator.DTMF(dtmfDigits(m), c.Level, dtmfTone, dtmfPause)
+	}
+	mark, space, err := frequencies(c.Modulation)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if c.SDMF {
+		data, err = m.MarshalSDMF()
+	} else {
+		data, err = m.MarshalMDMF()
+	}
+	if err != nil {
+		return nil, err
+	}
+	bits := make([]bool, c.Seizure+c.Marks)
+	for i := range bits {
+		bits[i] = i >= c.Seizure || i%2 == 1
+	}
+	bits = append(bits, generator.UART(data, 8, 1)...)
+	// A few mark bits let the last stop bit through the receive filters
+	bits = append(bits, true, true, true, true)
+	return generator.FSK(mark, space, baud, c.Level, bits), nil
+}
+
+// dtmfDigits returns the DTMF Caller ID string of a message: the number between
+// A and C, or the reason for its absence, 00 for unavailable or 10 for private,
+// between B and C
+func dtmfDigits(m *Message) string {
+	switch {
+	case m.Number != "":
+		return "A" + m.Number + "C"
+	case m.NumberReason == Private:
+		return "B10C"
+	}
+	return "B00C"
+}
+
+// Encode writes the Caller ID signal of a message to w in the given format
+func Encode(w io.Writer, m *Message, c Config, format int) error {
+	s, err := Signal(m, c)
+	if err != nil {
+		return err
+	}
+	r, err := generator.NewReader(s, format)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
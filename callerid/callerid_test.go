@@ -0,0 +1,135 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package callerid
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zaf/g711"
+)
+
+var msg = Message{
+	Time:   time.Date(0, 9, 30, 12, 24, 0, 0, time.UTC),
+	Number: "6095551212",
+	Name:   "ZAFIRIS L",
+}
+
+// decode runs a new Decoder over data in chunks of 160 bytes
+func decode(t *testing.T, data []byte, format int, m Modulation) []Message {
+	d, err := NewDecoder(format, m)
+	if err != nil {
+		t.Fatalf("Failed to create Decoder: %s\n", err)
+	}
+	var messages []Message
+	for len(data) > 0 {
+		n := 160
+		if n > len(data) {
+			n = len(data)
+		}
+		messages = append(messages, d.Process(data[:n])...)
+		data = data[n:]
+	}
+	return append(messages, d.Flush()...)
+}
+
+// Test the SDMF example of Bellcore GR-30
+func TestMarshal(t *testing.T) {
+	sdmf, err := msg.MarshalSDMF()
+	if err != nil {
+		t.Fatalf("MarshalSDMF failed: %s\n", err)
+	}
+	want := append([]byte{0x04, 0x12}, "09301224"+"6095551212"...)
+	want = append(want, 0x51)
+	if !bytes.Equal(sdmf, want) {
+		t.Errorf("SDMF expected: % x, actual: % x", want, sdmf)
+	}
+	mdmf, err := msg.MarshalMDMF()
+	if err != nil {
+		t.Fatalf("MarshalMDMF failed: %s\n", err)
+	}
+	var m Message
+	if err := m.Unmarshal(mdmf); err != nil || m != msg {
+		t.Errorf("MDMF round trip: %v %v", m, err)
+	}
+	mdmf[5] ^= 1
+	if err := m.Unmarshal(mdmf); err == nil {
+		t.Error("Checksum mismatch not detected")
+	}
+	private := Message{NumberReason: Private, NameReason: Private}
+	mdmf, _ = private.MarshalMDMF()
+	if err := m.Unmarshal(mdmf); err != nil || m != private {
+		t.Errorf("MDMF private round trip: %v %v", m, err)
+	}
+	if _, err := (&Message{Number: "1"}).MarshalSDMF(); err == nil {
+		t.Error("SDMF without time accepted")
+	}
+}
+
+// Test encoding and decoding in all formats and modulations
+func TestRoundTrip(t *testing.T) {
+	sdmf := Bell202Config
+	sdmf.SDMF = true
+	var tests = []struct {
+		name   string
+		config Config
+		want   Message
+	}{
+		{"Bell 202 MDMF", Bell202Config, msg},
+		{"Bell 202 SDMF", sdmf, Message{Time: msg.Time, Number: msg.Number}},
+		{"V.23 MDMF", V23Config, msg},
+		{"DTMF", DTMFConfig, Message{Number: msg.Number}},
+	}
+	for _, tc := range tests {
+		for _, format := range []int{g711.Lpcm, g711.Alaw, g711.Ulaw} {
+			var b bytes.Buffer
+			if err := Encode(&b, &msg, tc.config, format); err != nil {
+				t.Fatalf("%s: Encode failed: %s\n", tc.name, err)
+			}
+			messages := decode(t, b.Bytes(), format, tc.config.Modulation)
+			if len(messages) != 1 || messages[0] != tc.want {
+				t.Errorf("%s format %d: expected: %v, actual: %v", tc.name, format, tc.want, messages)
+			}
+		}
+	}
+	var b bytes.Buffer
+	if err := Encode(&b, &Message{NumberReason: Private}, DTMFConfig, g711.Alaw); err != nil {
+		t.Fatalf("Encode failed: %s\n", err)
+	}
+	if m := decode(t, b.Bytes(), g711.Alaw, DTMF); len(m) != 1 || m[0].NumberReason != Private {
+		t.Errorf("DTMF private: %v", m)
+	}
+}
+
+// Test decoding at low level and with a wrong modulation
+func TestDecode(t *testing.T) {
+	weak := Bell202Config
+	weak.Level = -35
+	var b bytes.Buffer
+	if err := Encode(&b, &msg, weak, g711.Ulaw); err != nil {
+		t.Fatalf("Encode failed: %s\n", err)
+	}
+	if m := decode(t, b.Bytes(), g711.Ulaw, Bell202); len(m) != 1 || m[0] != msg {
+		t.Errorf("-35dBm0: expected: %v, actual: %v", msg, m)
+	}
+	if _, err := NewDecoder(g711.Alaw, Modulation(7)); err == nil {
+		t.Error("Invalid modulation accepted")
+	}
+	data, err := os.ReadFile("../testing/speech.alaw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	for _, m := range []Modulation{Bell202, V23, DTMF} {
+		if messages := decode(t, data, g711.Alaw, m); len(messages) != 0 {
+			t.Errorf("Modulation %d: false messages from speech: %v", m, messages)
+		}
+	}
+}
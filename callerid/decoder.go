@@ -0,0 +1,157 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package callerid
+
+import (
+	"errors"
+
+	"github.com/zaf/g711/dtmf"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	minLevel = -40 // Lowest FSK level in dBm0
+	carrier  = 0.4 // Least fraction of the signal energy at the mark or space frequency
+)
+
+// Decoder decodes Caller ID messages from a stream of audio frames
+type Decoder struct {
+	format   int // input format
+	fsk      *dsp.FSK
+	uart     *dsp.UART
+	minFSK   float64 // signal energy in the FSK window at minLevel
+	dtmf     *dtmf.Detector
+	data     []byte // FSK message being received
+	digits   []byte // DTMF string being received
+	samples  []int16
+	messages []Message
+}
+
+// NewDecoder returns a pointer to a Decoder of Caller ID with the given modulation
+// for data in the given format
+func NewDecoder(format int, m Modulation) (*Decoder, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	d := &Decoder{format: format}
+	if m == DTMF {
+		var err error
+		d.dtmf, err = dtmf.NewDetector(format, dtmf.DefaultConfig)
+		return d, err
+	}
+	mark, space, err := frequencies(m)
+	if err != nil {
+		return nil, err
+	}
+	window := dsp.SampleRate / baud
+	a := dsp.Amplitude(minLevel)
+	d.fsk = dsp.NewFSK(mark, space, window)
+	d.uart = dsp.NewUART(baud, 8)
+	d.minFSK = a * a / 2 * float64(window)
+	return d, nil
+}
+
+// Process decodes a frame of audio data and returns the messages
+// completed within it.
+func (d *Decoder) Process(frame []byte) []Message {
+	d.samples = pcm.Decode(d.format, frame, d.samples[:0])
+	return d.ProcessSamples(d.samples)
+}
+
+// ProcessSamples decodes 16bit linear samples and returns the messages
+// completed within them.
+func (d *Decoder) ProcessSamples(s []int16) []Message {
+	d.messages = d.messages[:0]
+	if d.dtmf != nil {
+		for _, e := range d.dtmf.ProcessSamples(s) {
+			d.digit(e.Digit)
+		}
+		return d.messages
+	}
+	for _, x := range s {
+		v := d.fsk.Demod(float64(x))
+		if d.fsk.Energy < d.minFSK || d.fsk.Carrier() < carrier {
+			d.uart.Reset()
+			d.data = d.data[:0]
+			continue
+		}
+		if c, ok := d.uart.Sample(v > 0); ok {
+			d.char(c)
+		}
+	}
+	return d.messages
+}
+
+// Flush ends the stream and returns any message still pending
+func (d *Decoder) Flush() []Message {
+	d.messages = d.messages[:0]
+	if d.dtmf != nil {
+		for _, e := range d.dtmf.Flush() {
+			d.digit(e.Digit)
+		}
+	}
+	return d.messages
+}
+
+// Reset discards the Decoder state. This permits reusing a Decoder rather than allocating a new one.
+func (d *Decoder) Reset() {
+	if d.dtmf != nil {
+		d.dtmf.Reset()
+	} else {
+		d.fsk.Reset()
+		d.uart.Reset()
+	}
+	d.data, d.digits = d.data[:0], d.digits[:0]
+}
+
+// char adds a received character to the FSK message
+func (d *Decoder) char(c byte) {
+	if len(d.data) == 0 && c != sdmfType && c != mdmfType {
+		// Channel seizure and marks before the message type
+		return
+	}
+	d.data = append(d.data, c)
+	if len(d.data) < 2 || len(d.data) < int(d.data[1])+3 {
+		return
+	}
+	var m Message
+	if m.Unmarshal(d.data) == nil {
+		d.messages = append(d.messages, m)
+	}
+	d.data = d.data[:0]
+}
+
+// digit adds a received digit to the DTMF string. A or D start a number and
+// B a reason for its absence, C or # end them.
+func (d *Decoder) digit(c byte) {
+	switch c {
+	case 'A', 'B', 'D':
+		d.digits = append(d.digits[:0], c)
+	case 'C', '#':
+		if len(d.digits) == 0 {
+			return
+		}
+		var m Message
+		switch {
+		case d.digits[0] != 'B':
+			m.Number = string(d.digits[1:])
+		case string(d.digits[1:]) == "10":
+			m.NumberReason = Private
+		default:
+			m.NumberReason = Unavailable
+		}
+		d.messages = append(d.messages, m)
+		d.digits = d.digits[:0]
+	default:
+		if len(d.digits) > 0 {
+			d.digits = append(d.digits, c)
+		}
+	}
+}
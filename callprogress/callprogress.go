@@ -0,0 +1,409 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package callprogress classifies call progress and special information tones
+in G711 or LPCM audio.
+
+Dial, ringback, busy and congestion tones are recognised by their frequencies
+and cadence as defined in a country tone plan. Where a plan gives busy and
+congestion the same cadence they are reported as one tone. Special information
+tones (SIT) are recognised by the frequency and duration of their three
+segments as defined in ITU-T Q.35 and ANSI T1.401.
+*/
+package callprogress
+
+import (
+	"errors"
+	"math"
+
+	"github.com/zaf/g711/generator"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// Tone is a classified call progress tone
+type Tone int
+
+const (
+	// Call progress tones
+	Dial           Tone = iota // Dial tone
+	Ringback                   // Ringing tone
+	Busy                       // Busy tone
+	Congestion                 // Congestion or reorder tone
+	SITIntercept               // SIT, number changed or disconnected
+	SITVacantCode              // SIT, unassigned number
+	SITReorder                 // SIT, reorder announcement
+	SITNoCircuit               // SIT, all circuits busy
+	SIT                        // SIT of any other or unknown kind
+	BusyCongestion             // Busy or congestion tone of a plan that gives them the same cadence
+)
+
+const (
+	blockSize  = 320  // Samples per Goertzel block, 40ms with 25Hz resolution
+	minLevel   = -36  // Lowest level of each tone frequency in dBm0
+	toneEnergy = 0.7  // Least fraction of the block energy in the tone frequencies
+	maxTwist   = 10   // Largest power ratio between the frequencies of a tone
+	silence    = -50  // Highest level of silence in dBm0
+	continuous = 1000 // Duration of a continuous tone before it is reported in ms
+	tolerance  = 0.1  // Relative cadence tolerance
+	sitShort   = 274  // Short SIT segment in ms
+	sitLong    = 380  // Long SIT segment in ms
+	sitITU     = 330  // Q.35 SIT segment in ms
+	sitSlack   = 100  // SIT segment duration tolerance in ms
+	maxRuns    = 16   // Runs kept in the history
+	ms         = dsp.SampleRate / 1000
+	classQuiet = -1 // Block class of silence
+	classOther = -2 // Block class of any other signal
+)
+
+var names = [...]string{"dial", "ringback", "busy", "congestion", "SIT intercept", "SIT vacant code", "SIT reorder", "SIT no circuit", "SIT", "busy or congestion"}
+
+func (t Tone) String() string {
+	if t < 0 || int(t) >= len(names) {
+		return "unknown"
+	}
+	return names[t]
+}
+
+// Event is a classified tone
+type Event struct {
+	Tone  Tone  // Tone class
+	Start int64 // Sample offset of the start of the tone
+	End   int64 // Sample offset of the end of the tone when it was classified
+}
+
+var (
+	// SIT segment frequencies
+	sitLow  = [3]float64{913.8, 1370.6, 1776.7}
+	sitHigh = [2]float64{985.2, 1428.5}
+	sitQ35  = [3]float64{950, 1400, 1800}
+	// ANSI T1.401 SIT codes by segment frequency (low/high) and duration (short/long)
+	sitCodes = []struct {
+		tone Tone
+		high [2]bool
+		long [2]bool
+	}{
+		{SITIntercept, [2]bool{false, false}, [2]bool{false, false}},
+		{SITVacantCode, [2]bool{true, false}, [2]bool{true, false}},
+		{SITReorder, [2]bool{false, true}, [2]bool{false, true}},
+		{SITReorder, [2]bool{true, false}, [2]bool{false, true}},
+		{SITNoCircuit, [2]bool{true, true}, [2]bool{true, true}},
+		{SITNoCircuit, [2]bool{false, false}, [2]bool{true, true}},
+	}
+)
+
+// segment is a cadence segment as a block class and a duration in samples
+type segment struct {
+	class    int
+	duration int64
+}
+
+// run is a sequence of blocks of the same class
+type run struct {
+	class      int
+	start, end int64
+}
+
+// Detector classifies call progress tones in a stream of audio frames
+type Detector struct {
+	format     int              // input format
+	signatures [][]dsp.Goertzel // frequency sets of the tones
+	cadences   [4][]segment     // cadences of the plan tones
+	tones      [4]Tone          // reported tone of each cadence
+	sit        map[int]float64  // SIT segment frequency by class
+	steady     map[int]int64    // duration of a continuous tone before it is reported by class
+	minPower   float64          // Goertzel power at minLevel
+	framer     dsp.Framer
+	samples    []int16 // decoding buffer
+	offset     int64   // sample offset of the next block
+	runs       []run   // completed runs, oldest first
+	current    run     // run in progress
+	last       Event   // last reported event
+	reported   bool    // an event was reported
+	events     []Event
+}
+
+// NewDetector returns a pointer to a Detector for data in the given format that
+// recognises the call progress tones of a tone plan, such as generator.Plans["us"].
+func NewDetector(format int, plan generator.Plan) (*Detector, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	p := dsp.Amplitude(minLevel) * blockSize / 2
+	d := &Detector{
+		format:   format,
+		sit:      make(map[int]float64),
+		steady:   make(map[int]int64),
+		minPower: p * p,
+		framer:   dsp.Framer{Size: blockSize},
+		current:  run{class: classOther},
+	}
+	for i, c := range []generator.Cadence{plan.Dial, plan.Ringback, plan.Busy, plan.Congestion} {
+		if len(c.Segments) == 0 {
+			return nil, errors.New("empty cadence in tone plan")
+		}
+		for _, s := range c.Segments {
+			d.cadences[i] = append(d.cadences[i], segment{d.class(s.Freqs), int64(s.Duration * ms)})
+		}
+		d.tones[i] = Tone(i)
+	}
+	// Busy and congestion tones that differ only in level are matched once
+	if sameCadence(d.cadences[Busy], d.cadences[Congestion]) {
+		d.tones[Busy], d.cadences[Congestion] = BusyCongestion, nil
+	}
+	// A continuous tone must outlast the tones of other cadences with the same frequencies
+	for _, cad := range d.cadences {
+		if len(cad) == 1 {
+			d.steady[cad[0].class] = continuous * ms
+		}
+	}
+	for _, cad := range d.cadences {
+		for _, s := range cad {
+			if t, ok := d.steady[s.class]; ok && len(cad) > 1 {
+				if l := s.duration + int64(tolerance*float64(s.duration)) + blockSize; l > t {
+					d.steady[s.class] = l
+				}
+			}
+		}
+	}
+	for _, f := range append(append(sitLow[:], sitHigh[:]...), sitQ35[:]...) {
+		d.sit[d.class([]float64{f})] = f
+	}
+	return d, nil
+}
+
+// class returns the block class of a set of frequencies, adding a signature if needed
+func (d *Detector) class(freqs []float64) int {
+	if len(freqs) == 0 {
+		return classQuiet
+	}
+next:
+	for i, sig := range d.signatures {
+		if len(sig) != len(freqs) {
+			continue
+		}
+		for j := range sig {
+			if sig[j].Freq != freqs[j] {
+				continue next
+			}
+		}
+		return i
+	}
+	var sig []dsp.Goertzel
+	for _, f := range freqs {
+		sig = append(sig, dsp.NewGoertzel(f))
+	}
+	d.signatures = append(d.signatures, sig)
+	return len(d.signatures) - 1
+}
+
+// Process classifies tones in a frame of audio data and returns the
+// tones classified within it.
+func (d *Detector) Process(frame []byte) []Event {
+	d.samples = pcm.Decode(d.format, frame, d.samples[:0])
+	return d.ProcessSamples(d.samples)
+}
+
+// ProcessSamples classifies tones in 16bit linear samples and returns the
+// tones classified within them.
+func (d *Detector) ProcessSamples(s []int16) []Event {
+	d.events = d.events[:0]
+	d.framer.Push(s, d.block)
+	return d.events
+}
+
+// Reset discards the Detector state. This permits reusing a Detector rather than allocating a new one.
+func (d *Detector) Reset() {
+	d.framer.Reset()
+	d.offset, d.runs, d.current, d.reported = 0, d.runs[:0], run{class: classOther}, false
+}
+
+// block classifies a single block and updates the runs
+func (d *Detector) block(s []float64) {
+	c := d.classify(s)
+	start := d.offset
+	d.offset += blockSize
+	if c == d.current.class {
+		d.current.end = d.offset
+	} else {
+		d.endRun()
+		d.current = run{class: c, start: start, end: d.offset}
+	}
+	// Continuous tones are reported while they last
+	for i, cad := range d.cadences {
+		if len(cad) == 1 && cad[0].class == c && d.current.end-d.current.start > d.steady[c] {
+			d.report(d.tones[i], d.current.start, d.current.end)
+		}
+	}
+}
+
+// endRun moves the current run to the history and matches the cadences
+func (d *Detector) endRun() {
+	r := d.current
+	if r.end == r.start {
+		return
+	}
+	// A single block is a transition between two signals, it is part of the previous run
+	if r.end-r.start == blockSize && len(d.runs) > 0 {
+		d.runs[len(d.runs)-1].end = r.end
+		return
+	}
+	if n := len(d.runs); n > 0 && d.runs[n-1].class == r.class {
+		d.runs[n-1].end = r.end
+	} else {
+		d.runs = append(d.runs, r)
+	}
+	if len(d.runs) > maxRuns {
+		d.runs = d.runs[len(d.runs)-maxRuns:]
+	}
+	d.matchSIT()
+	for i, cad := range d.cadences {
+		if len(cad) > 1 && d.matchCadence(cad) {
+			d.report(d.tones[i], d.runs[len(d.runs)-len(cad)-1].start, r.end)
+		}
+	}
+}
+
+// matchCadence reports whether the last runs form a full period of a cadence and
+// the first segment of the next period, which tells apart cadences that start alike
+func (d *Detector) matchCadence(cad []segment) bool {
+	if len(d.runs) < len(cad)+1 {
+		return false
+	}
+	runs := d.runs[len(d.runs)-len(cad)-1:]
+rotation:
+	for r := range cad {
+		for j, run := range runs {
+			s := cad[(r+j)%len(cad)]
+			slack := int64(tolerance*float64(s.duration)) + blockSize
+			if run.class != s.class || abs(run.end-run.start-s.duration) > slack {
+				continue rotation
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// sameCadence reports whether two cadences have the same segments
+func sameCadence(a, b []segment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSIT classifies the last three runs as a special information tone
+func (d *Detector) matchSIT() {
+	if len(d.runs) < 3 {
+		return
+	}
+	runs := d.runs[len(d.runs)-3:]
+	var freqs [3]float64
+	var durations [3]int64
+	for i, r := range runs {
+		f, ok := d.sit[r.class]
+		if !ok {
+			return
+		}
+		freqs[i], durations[i] = f, (r.end-r.start)/ms
+	}
+	// ITU-T Q.35
+	if freqs == sitQ35 {
+		for _, t := range durations {
+			if abs(t-sitITU) > sitSlack {
+				return
+			}
+		}
+		d.report(SIT, runs[0].start, runs[2].end)
+		return
+	}
+	// ANSI T1.401
+	if freqs[2] != sitLow[2] || durations[2] < sitLong-sitSlack {
+		return
+	}
+	var high, long [2]bool
+	for i := 0; i < 2; i++ {
+		switch freqs[i] {
+		case sitLow[i]:
+		case sitHigh[i]:
+			high[i] = true
+		default:
+			return
+		}
+		if abs(durations[i]-sitShort) > sitSlack && abs(durations[i]-sitLong) > sitSlack {
+			return
+		}
+		long[i] = durations[i] > (sitShort+sitLong)/2
+	}
+	tone := SIT
+	for _, code := range sitCodes {
+		if code.high == high && code.long == long {
+			tone = code.tone
+			break
+		}
+	}
+	d.report(tone, runs[0].start, runs[2].end)
+}
+
+// report adds an event unless it continues the last one
+func (d *Detector) report(t Tone, start, end int64) {
+	if d.reported && d.last.Tone == t && start <= d.last.End {
+		d.last.End = end
+		return
+	}
+	d.last, d.reported = Event{Tone: t, Start: start, End: end}, true
+	d.events = append(d.events, d.last)
+}
+
+// classify returns the class of a block
+func (d *Detector) classify(s []float64) int {
+	energy := dsp.Energy(s)
+	if dsp.Level(energy/float64(len(s))) < silence {
+		return classQuiet
+	}
+	best, bestShare := classOther, 0.0
+	var power []float64
+	for i, sig := range d.signatures {
+		var sum, peak float64
+		power = power[:0]
+		for _, g := range sig {
+			p := g.Power(s)
+			sum += p
+			peak = math.Max(peak, p)
+			power = append(power, p)
+		}
+		// Every frequency must be present at a similar level
+		present := true
+		for _, p := range power {
+			if p < d.minPower || p*maxTwist < peak {
+				present = false
+			}
+		}
+		if !present {
+			continue
+		}
+		if share := dsp.ToneEnergy(sum, len(s)) / energy; share >= toneEnergy && share > bestShare {
+			best, bestShare = i, share
+		}
+	}
+	return best
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,113 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package callprogress
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/generator"
+)
+
+// detect runs a new Detector over a signal encoded to A-law
+func detect(t *testing.T, plan generator.Plan, s generator.Signal) []Event {
+	r, _ := generator.NewReader(s, g711.Alaw)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Reading failed: %s\n", err)
+	}
+	return detectData(t, plan, data, g711.Alaw)
+}
+
+func detectData(t *testing.T, plan generator.Plan, data []byte, format int) []Event {
+	d, err := NewDetector(format, plan)
+	if err != nil {
+		t.Fatalf("Failed to create Detector: %s\n", err)
+	}
+	var events []Event
+	for len(data) > 0 {
+		n := 160
+		if n > len(data) {
+			n = len(data)
+		}
+		events = append(events, d.Process(data[:n])...)
+		data = data[n:]
+	}
+	return events
+}
+
+// Test the call progress tones of every plan
+func TestCallProgress(t *testing.T) {
+	for country, plan := range generator.Plans {
+		for tone, c := range []generator.Cadence{plan.Dial, plan.Ringback, plan.Busy, plan.Congestion} {
+			// Start in the middle of the cadence, after some silence
+			signal := generator.Sequence(generator.Silence(700), c.Signal(20000))
+			events := detect(t, plan, signal)
+			want := Tone(tone)
+			if (want == Busy || want == Congestion) && reflect.DeepEqual(plan.Busy.Segments, plan.Congestion.Segments) {
+				want = BusyCongestion
+			}
+			if len(events) != 1 || events[0].Tone != want {
+				t.Errorf("%s %s: unexpected events: %v", country, want, events)
+			}
+		}
+	}
+}
+
+// sit returns a special information tone followed by silence
+func sit(freqs [3]float64, durations [3]int) generator.Signal {
+	var s []generator.Signal
+	for i := range freqs {
+		s = append(s, generator.Sine(freqs[i], -24, durations[i]))
+	}
+	return generator.Sequence(append(s, generator.Silence(1000))...)
+}
+
+// Test the special information tones
+func TestSIT(t *testing.T) {
+	var tests = []struct {
+		freqs     [3]float64
+		durations [3]int
+		tone      Tone
+	}{
+		{[3]float64{913.8, 1370.6, 1776.7}, [3]int{274, 274, 380}, SITIntercept},
+		{[3]float64{985.2, 1370.6, 1776.7}, [3]int{380, 274, 380}, SITVacantCode},
+		{[3]float64{913.8, 1428.5, 1776.7}, [3]int{274, 380, 380}, SITReorder},
+		{[3]float64{985.2, 1428.5, 1776.7}, [3]int{380, 380, 380}, SITNoCircuit},
+		{[3]float64{985.2, 1428.5, 1776.7}, [3]int{274, 274, 380}, SIT},
+		{[3]float64{950, 1400, 1800}, [3]int{330, 330, 330}, SIT},
+	}
+	for _, tc := range tests {
+		events := detect(t, generator.Plans["us"], sit(tc.freqs, tc.durations))
+		if len(events) != 1 || events[0].Tone != tc.tone {
+			t.Errorf("%v: expected: %s, actual: %v", tc.freqs, tc.tone, events)
+			continue
+		}
+		end := int64(8 * (tc.durations[0] + tc.durations[1] + tc.durations[2]))
+		if e := events[0]; e.Start > blockSize || e.End < end-2*blockSize || e.End > end+2*blockSize {
+			t.Errorf("%s: unexpected timestamps: %v", tc.tone, e)
+		}
+	}
+}
+
+// Test that speech is not classified
+func TestSpeech(t *testing.T) {
+	data, err := os.ReadFile("../testing/speech.ulaw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	for country, plan := range generator.Plans {
+		if events := detectData(t, plan, data, g711.Ulaw); len(events) != 0 {
+			t.Errorf("%s: false detection: %v", country, events)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package g711
+
+import (
+	"errors"
+	"io"
+)
+
+// Downmix selects the average of all input channels for encoding
+const Downmix = -1
+
+// sampleSize returns the bytes per sample of a format
+func sampleSize(format int) (int, error) {
+	switch format {
+	case Alaw, Ulaw:
+		return 1, nil
+	case Lpcm:
+		return 2, nil
+	}
+	return 0, errors.New("invalid format")
+}
+
+// SetChannels sets the number of interleaved channels of the G711 data the Decoder reads.
+// Decoding keeps the channels interleaved, the setting keeps them apart when resampling.
+func (r *Decoder) SetChannels(channels int) error {
+	if channels < 1 {
+		return errors.New("invalid number of channels")
+	}
+	r.channels = channels
+	return r.SetOutputRate(r.rate)
+}
+
+// SetInputChannels sets the number of interleaved channels of the LPCM data the Encoder takes
+// and the channel it encodes, counting from 0, or Downmix for the average of all channels.
+func (w *Encoder) SetInputChannels(channels, channel int) error {
+	if w.input != Lpcm {
+		return errors.New("input channels require LPCM input")
+	}
+	if channels < 1 || channel < Downmix || channel >= channels {
+		return errors.New("invalid channel")
+	}
+	w.channels, w.channel = channels, channel
+	w.partial = w.partial[:0]
+	return nil
+}
+
+// toMono converts interleaved LPCM data to a single channel, keeping any incomplete frame
+func (w *Encoder) toMono(p []byte) []byte {
+	size := 2 * w.channels
+	data := append(w.partial, p...)
+	whole := len(data) / size * size
+	w.mono = w.mono[:0]
+	for f := 0; f < whole; f += size {
+		var x int
+		if w.channel == Downmix {
+			for c := 0; c < w.channels; c++ {
+				x += int(int16(data[f+2*c]) | int16(data[f+2*c+1])<<8)
+			}
+			x /= w.channels
+		} else {
+			x = int(int16(data[f+2*w.channel]) | int16(data[f+2*w.channel+1])<<8)
+		}
+		w.mono = append(w.mono, byte(x), byte(x>>8))
+	}
+	w.partial = append(w.partial[:0], data[whole:]...)
+	return w.mono
+}
+
+// Split separates interleaved data of the given format into one slice per channel.
+// An incomplete trailing frame is ignored.
+func Split(data []byte, format, channels int) ([][]byte, error) {
+	size, err := sampleSize(format)
+	if err != nil {
+		return nil, err
+	}
+	if channels < 1 {
+		return nil, errors.New("invalid number of channels")
+	}
+	frames := len(data) / (size * channels)
+	streams := make([][]byte, channels)
+	for c := range streams {
+		streams[c] = make([]byte, 0, frames*size)
+	}
+	for f := 0; f < frames; f++ {
+		for c := range streams {
+			i := (f*channels + c) * size
+			streams[c] = append(streams[c], data[i:i+size]...)
+		}
+	}
+	return streams, nil
+}
+
+// Merge interleaves per channel data of the given format. The output
+// has as many frames as the shortest channel.
+func Merge(streams [][]byte, format int) ([]byte, error) {
+	size, err := sampleSize(format)
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 {
+		return nil, errors.New("no channels")
+	}
+	frames := len(streams[0]) / size
+	for _, s := range streams[1:] {
+		if n := len(s) / size; n < frames {
+			frames = n
+		}
+	}
+	data := make([]byte, 0, frames*size*len(streams))
+	for f := 0; f < frames; f++ {
+		for _, s := range streams {
+			data = append(data, s[f*size:(f+1)*size]...)
+		}
+	}
+	return data, nil
+}
+
+// Splitter is an io.Writer that separates interleaved data into per channel writers
+type Splitter struct {
+	size    int // bytes per sample
+	dest    []io.Writer
+	partial []byte // bytes of an incomplete frame
+}
+
+// NewSplitter returns a pointer to a Splitter of interleaved data of the given format
+// that writes channel i to writers[i]
+func NewSplitter(format int, writers ...io.Writer) (*Splitter, error) {
+	size, err := sampleSize(format)
+	if err != nil {
+		return nil, err
+	}
+	if len(writers) == 0 {
+		return nil, errors.New("no channels")
+	}
+	for _, w := range writers {
+		if w == nil {
+			return nil, errors.New("io.Writer is nil")
+		}
+	}
+	return &Splitter{size: size, dest: writers}, nil
+}
+
+// Write separates the channels of p and writes them to their writers. It returns
+// the number of bytes consumed and any error encountered.
+func (s *Splitter) Write(p []byte) (int, error) {
+	data := append(s.partial, p...)
+	frame := s.size * len(s.dest)
+	whole := len(data) / frame * frame
+	streams, _ := Split(data[:whole], formatOfSize(s.size), len(s.dest))
+	s.partial = append(s.partial[:0], data[whole:]...)
+	for c, w := range s.dest {
+		if _, err := w.Write(streams[c]); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Merger is an io.Reader that interleaves data read from per channel readers
+type Merger struct {
+	format  int    // data format
+	size    int    // bytes per sample
+	silence []byte // a silent sample
+	sources []io.Reader
+	ended   []bool // channels that reached EOF
+	buf     [][]byte
+	pending []byte // interleaved data not yet read
+}
+
+// NewMerger returns a pointer to a Merger of data of the given format
+// that reads channel i from readers[i]. Channels that end before the
+// others are filled with silence up to the end of the longest one.
+func NewMerger(format int, readers ...io.Reader) (*Merger, error) {
+	size, err := sampleSize(format)
+	if err != nil {
+		return nil, err
+	}
+	if len(readers) == 0 {
+		return nil, errors.New("no channels")
+	}
+	for _, r := range readers {
+		if r == nil {
+			return nil, errors.New("io.Reader is nil")
+		}
+	}
+	silence := []byte{0, 0}
+	switch format {
+	case Alaw:
+		silence = []byte{EncodeAlawFrame(0)}
+	case Ulaw:
+		silence = []byte{EncodeUlawFrame(0)}
+	}
+	return &Merger{
+		format:  format,
+		size:    size,
+		silence: silence,
+		sources: readers,
+		ended:   make([]bool, len(readers)),
+		buf:     make([][]byte, len(readers)),
+	}, nil
+}
+
+// Read reads the same number of samples from every channel and interleaves them into p.
+// It returns the number of bytes read and io.EOF once all channels end.
+func (m *Merger) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(m.pending) == 0 {
+		if err := m.fill(len(p)); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, m.pending)
+	m.pending = m.pending[n:]
+	return n, nil
+}
+
+// fill reads up to the samples of n interleaved bytes from every channel and merges
+// them, filling the channels that ended with silence
+func (m *Merger) fill(n int) error {
+	frames := n / (m.size * len(m.sources))
+	if frames == 0 {
+		frames = 1
+	}
+	longest := 0
+	for c, r := range m.sources {
+		if cap(m.buf[c]) < frames*m.size {
+			m.buf[c] = make([]byte, frames*m.size)
+		}
+		m.buf[c] = m.buf[c][:frames*m.size]
+		read := 0
+		if !m.ended[c] {
+			var err error
+			read, err = io.ReadFull(r, m.buf[c])
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				m.ended[c], err = true, nil
+			}
+			if err != nil {
+				return err
+			}
+			read = read / m.size * m.size
+		}
+		for i := read; i < len(m.buf[c]); i += m.size {
+			copy(m.buf[c][i:], m.silence)
+		}
+		if read > longest {
+			longest = read
+		}
+	}
+	if longest == 0 {
+		return io.EOF
+	}
+	for c := range m.buf {
+		m.buf[c] = m.buf[c][:longest]
+	}
+	m.pending, _ = Merge(m.buf, m.format)
+	return nil
+}
+
+// formatOfSize returns a format with the given sample size
+func formatOfSize(size int) int {
+	if size == 2 {
+		return Lpcm
+	}
+	return Alaw
+}
@@ -0,0 +1,358 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package classifier labels G711 or LPCM audio as speech, music, tone or silence,
+such as for measuring the time a caller spends on hold music.
+
+The audio is analysed in frames of 32ms for their level, zero crossing rate,
+spectral flux, harmonicity and spectral peaks. Each window of 32 frames is
+then labelled from the frame statistics: silence and tones by the share of
+silent or steady tonal frames, speech and music by the alternation of voiced,
+unvoiced and silent sounds that sets speech apart. Consecutive windows of the
+same class form a segment, with the mean confidence of its windows.
+*/
+package classifier
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/cmplx"
+
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// Class is an audio class
+type Class int
+
+const (
+	// Audio classes
+	Silence Class = iota // Silence or background noise below the silence level
+	Speech               // Speech
+	Music                // Music
+	Tone                 // Steady tones, such as call progress tones
+)
+
+const (
+	frameSize    = 256 // Samples per frame, 32ms
+	windowFrames = 32  // Frames per classified window, 1.024s
+	minFrames    = 8   // Least frames of a trailing window to classify it
+	silenceLevel = -45 // Highest frame level of silence in dBm0
+	majority     = 0.6 // Least share of silent frames that labels a window silence
+	toneShare    = 0.9 // Least share of tonal frames among the loud ones that labels a window tone
+	loudRange    = 3   // Level range of the loud frames below the loudest in dB
+	peakBins     = 2   // Bins on each side of a spectral peak
+	tonePurity   = 0.9 // Least fraction of a tonal frame's energy in its two highest peaks
+	minLag       = 20  // Shortest pitch period, 400Hz
+	maxLag       = 160 // Longest pitch period, 50Hz
+)
+
+var names = [...]string{"silence", "speech", "music", "tone"}
+
+func (c Class) String() string {
+	if c < 0 || int(c) >= len(names) {
+		return "unknown"
+	}
+	return names[c]
+}
+
+// speech and music votes of the window features, as the centre and the
+// half width of the transition from music to speech
+var votes = []struct {
+	centre, width, weight float64
+}{
+	{0.15, 0.2, 1},     // share of frames below half the mean power
+	{0.04, 0.04, 1},    // standard deviation of the zero crossing rate
+	{0.015, 0.01, 1},   // mean spectral flux
+	{0.75, -0.15, 0.5}, // mean harmonicity, higher in music
+}
+
+// Segment is a classified part of the audio
+type Segment struct {
+	Class      Class
+	Start      int64   // Sample offset of the start of the segment
+	End        int64   // Sample offset of the end of the segment
+	Confidence float64 // Mean confidence of the classification, 0.5 to 1
+}
+
+// frame holds the features of a frame
+type frame struct {
+	power    float64 // mean power per sample
+	zcr      float64 // zero crossings per sample
+	flux     float64 // change of the normalised spectrum from the previous frame
+	harmonic float64 // highest normalised autocorrelation in the pitch range
+	peak     int     // bin of the spectral peak
+	silent   bool
+	tonal    bool
+}
+
+// Classifier labels a stream of audio frames
+type Classifier struct {
+	format   int // input format
+	hann     []float64
+	spec     []complex128
+	mag      []float64 // normalised magnitude spectrum of the last frame
+	prev     []float64 // normalised magnitude spectrum of the frame before
+	peak     int       // spectral peak of the last frame
+	framer   dsp.Framer
+	samples  []int16 // decoding buffer
+	frames   []frame // frames of the current window
+	offset   int64   // sample offset of the start of the current window
+	current  Segment // segment in progress
+	windows  int     // windows in the current segment
+	segments []Segment
+}
+
+// New returns a pointer to a Classifier for data in the given format
+func New(format int) (*Classifier, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	c := &Classifier{
+		format: format,
+		hann:   make([]float64, frameSize),
+		spec:   make([]complex128, frameSize),
+		mag:    make([]float64, frameSize/2+1),
+		prev:   make([]float64, frameSize/2+1),
+		framer: dsp.Framer{Size: frameSize},
+	}
+	for i := range c.hann {
+		c.hann[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/frameSize)
+	}
+	return c, nil
+}
+
+// Classify reads data in the given format from r until EOF and returns its segments
+func Classify(r io.Reader, format int) ([]Segment, error) {
+	c, err := New(format)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := pcm.NewReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+	var segments []Segment
+	s := make([]int16, 4096)
+	for {
+		n, err := pr.Read(s)
+		segments = append(segments, c.ProcessSamples(s[:n])...)
+		if err == io.EOF {
+			return append(segments, c.Flush()...), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Process classifies a frame of audio data and returns the segments that ended within it
+func (c *Classifier) Process(frame []byte) []Segment {
+	c.samples = pcm.Decode(c.format, frame, c.samples[:0])
+	return c.ProcessSamples(c.samples)
+}
+
+// ProcessSamples classifies 16bit linear samples and returns the segments that ended within them
+func (c *Classifier) ProcessSamples(s []int16) []Segment {
+	c.segments = c.segments[:0]
+	c.framer.Push(s, c.block)
+	return c.segments
+}
+
+// Flush ends the stream and returns the remaining segments. A trailing window
+// shorter than a quarter of a second is not classified.
+func (c *Classifier) Flush() []Segment {
+	c.segments = c.segments[:0]
+	if len(c.frames) >= minFrames {
+		c.window()
+	}
+	if c.windows > 0 {
+		c.emit()
+	}
+	c.frames = c.frames[:0]
+	return c.segments
+}
+
+// Reset discards the Classifier state. This permits reusing a Classifier rather than allocating a new one.
+func (c *Classifier) Reset() {
+	c.framer.Reset()
+	c.frames, c.offset, c.windows, c.peak = c.frames[:0], 0, 0, 0
+	for i := range c.mag {
+		c.mag[i], c.prev[i] = 0, 0
+	}
+}
+
+// block extracts the features of a frame and classifies full windows
+func (c *Classifier) block(s []float64) {
+	var f frame
+	f.power = dsp.Energy(s) / frameSize
+	f.silent = dsp.Level(f.power) < silenceLevel
+	for i := 1; i < len(s); i++ {
+		if (s[i] >= 0) != (s[i-1] >= 0) {
+			f.zcr++
+		}
+	}
+	f.zcr /= frameSize
+	// A tonal frame keeps the peak of the previous frame, unless it starts a tone
+	peak, start := c.peak, len(c.frames) == 0 || !c.frames[len(c.frames)-1].tonal
+	c.prev, c.mag = c.mag, c.prev
+	f.tonal = c.spectrum(s) && !f.silent && (start || abs(c.peak-peak) <= peakBins)
+	for k := range c.mag {
+		d := c.mag[k] - c.prev[k]
+		f.flux += d * d
+	}
+	f.harmonic = harmonicity(s)
+	f.peak = c.peak
+	c.frames = append(c.frames, f)
+	if len(c.frames) == windowFrames {
+		c.window()
+		c.frames = c.frames[:0]
+	}
+}
+
+// spectrum computes the normalised magnitude spectrum of a frame and its peak.
+// It reports whether two peaks hold most of the frame energy.
+func (c *Classifier) spectrum(s []float64) bool {
+	for i, x := range s {
+		c.spec[i] = complex(x*c.hann[i], 0)
+	}
+	dsp.FFT(c.spec)
+	var sum, energy float64
+	c.peak = 0
+	for k := range c.mag {
+		c.mag[k] = cmplx.Abs(c.spec[k])
+		sum += c.mag[k]
+		energy += c.mag[k] * c.mag[k]
+		if c.mag[k] > c.mag[c.peak] {
+			c.peak = k
+		}
+	}
+	if sum == 0 {
+		return false
+	}
+	// Energy around the highest peak and the highest bin outside it
+	second := -1
+	for k := range c.mag {
+		if abs(k-c.peak) > peakBins && (second < 0 || c.mag[k] > c.mag[second]) {
+			second = k
+		}
+	}
+	var tone float64
+	for k := range c.mag {
+		if abs(k-c.peak) <= peakBins || abs(k-second) <= peakBins {
+			tone += c.mag[k] * c.mag[k]
+		}
+	}
+	for k := range c.mag {
+		c.mag[k] /= sum
+	}
+	return tone >= tonePurity*energy
+}
+
+// harmonicity returns the highest normalised autocorrelation of a frame in the pitch range
+func harmonicity(s []float64) float64 {
+	var best float64
+	for lag := minLag; lag <= maxLag; lag++ {
+		var xy, xx, yy float64
+		for i := 0; i+lag < len(s); i++ {
+			xy += s[i] * s[i+lag]
+			xx += s[i] * s[i]
+			yy += s[i+lag] * s[i+lag]
+		}
+		if xx > 0 && yy > 0 {
+			best = math.Max(best, xy/math.Sqrt(xx*yy))
+		}
+	}
+	return best
+}
+
+// window classifies the frames of the current window
+func (c *Classifier) window() {
+	n := float64(len(c.frames))
+	var silent, peak, mean, zcr, flux, harmonic float64
+	for _, f := range c.frames {
+		if f.silent {
+			silent++
+		}
+		peak = math.Max(peak, f.power)
+		mean += f.power / n
+		zcr += f.zcr / n
+		flux += f.flux / n
+		harmonic += f.harmonic / n
+	}
+	// Tones are judged on the loud frames, without the edges of interrupted tones,
+	// and keep their spectral peak through the window
+	var low, spread, loud, tonal float64
+	lowest, highest := frameSize, 0
+	for _, f := range c.frames {
+		if f.power < mean/2 {
+			low++
+		}
+		if f.power >= peak*dsp.DB(-loudRange) {
+			loud++
+			if f.tonal {
+				tonal++
+				if f.peak < lowest {
+					lowest = f.peak
+				}
+				if f.peak > highest {
+					highest = f.peak
+				}
+			}
+		}
+		spread += (f.zcr - zcr) * (f.zcr - zcr) / n
+	}
+	var class Class
+	var confidence float64
+	switch {
+	case silent >= majority*n:
+		class, confidence = Silence, silent/n
+	case tonal >= toneShare*loud && highest-lowest <= 2*peakBins:
+		class, confidence = Tone, tonal/loud
+	default:
+		// Weighted mean of the votes for speech, -1 to 1
+		var score, weights float64
+		for i, x := range []float64{low / n, math.Sqrt(spread), flux, harmonic} {
+			v := votes[i]
+			score += v.weight * math.Max(-1, math.Min(1, (x-v.centre)/v.width))
+			weights += v.weight
+		}
+		score /= weights
+		class, confidence = Speech, 0.5+score/2
+		if score < 0 {
+			class, confidence = Music, 0.5-score/2
+		}
+	}
+	end := c.offset + int64(len(c.frames))*frameSize
+	if c.windows > 0 && class != c.current.Class {
+		c.emit()
+	}
+	if c.windows == 0 {
+		c.current = Segment{Class: class, Start: c.offset}
+	}
+	c.current.End = end
+	c.current.Confidence += confidence
+	c.windows++
+	c.offset = end
+}
+
+// emit reports the segment in progress
+func (c *Classifier) emit() {
+	c.current.Confidence /= float64(c.windows)
+	c.segments = append(c.segments, c.current)
+	c.windows = 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,145 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package classifier
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/generator"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// music returns ms milliseconds of a sequence of three note chords with harmonics
+// and a hi-hat on every beat
+func music(ms int, seed int64) []int16 {
+	notes := []float64{261.6, 293.7, 329.6, 349.2, 392, 440, 493.9, 523.3}
+	rng := rand.New(rand.NewSource(seed))
+	s := make([]int16, ms*8)
+	var phase [3][6]float64
+	var chord [3]float64
+	next := 0
+	for i := range s {
+		if i == next {
+			next += 2000 + rng.Intn(2000)
+			r := rng.Intn(len(notes))
+			chord = [3]float64{notes[r], notes[(r+2)%len(notes)], notes[(r+4)%len(notes)] / 2}
+		}
+		var x float64
+		for c, f := range chord {
+			for h := range phase[c] {
+				phase[c][h] += 2 * math.Pi * f * float64(h+1) / 8000
+				x += math.Sin(phase[c][h]) / float64((h+1)*(h+1))
+			}
+		}
+		// Hi-hat, decaying noise every 250ms
+		x += rng.NormFloat64() * 0.5 * math.Exp(-float64(i%2000)/200)
+		s[i] = int16(math.Round(2000 * x))
+	}
+	return s
+}
+
+// render returns the samples of a signal
+func render(t *testing.T, s generator.Signal) []int16 {
+	r, _ := generator.NewReader(s, g711.Lpcm)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Reading failed: %s\n", err)
+	}
+	return pcm.Decode(g711.Lpcm, data, nil)
+}
+
+// speech returns the samples of the test speech recording
+func speech(t *testing.T) []int16 {
+	data, err := os.ReadFile("../testing/speech.raw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	return pcm.Decode(g711.Lpcm, data, nil)
+}
+
+// classify runs a new Classifier over samples encoded in format, in chunks of 160 bytes
+func classify(t *testing.T, s []int16, format int) []Segment {
+	c, err := New(format)
+	if err != nil {
+		t.Fatalf("Failed to create Classifier: %s\n", err)
+	}
+	data := pcm.Encode(format, s, nil)
+	var segments []Segment
+	for len(data) > 0 {
+		n := 160
+		if n > len(data) {
+			n = len(data)
+		}
+		segments = append(segments, c.Process(data[:n])...)
+		data = data[n:]
+	}
+	return append(segments, c.Flush()...)
+}
+
+// Test the classification of single class signals
+func TestClasses(t *testing.T) {
+	dial, _ := generator.CallProgress("us", "dial", 5000)
+	busy, _ := generator.CallProgress("us", "busy", 5000)
+	var tests = []struct {
+		name  string
+		s     []int16
+		class Class
+	}{
+		{"silence", render(t, generator.Silence(3000)), Silence},
+		{"quiet noise", render(t, generator.WhiteNoise(-55, 3000, 1)), Silence},
+		{"speech", speech(t)[:2*8192], Speech},
+		{"music", music(10000, 1), Music},
+		{"sine", render(t, generator.Sine(1000, -10, 3000)), Tone},
+		{"dial", render(t, dial), Tone},
+		{"busy", render(t, busy), Tone},
+	}
+	for _, tc := range tests {
+		for _, format := range []int{g711.Alaw, g711.Ulaw, g711.Lpcm} {
+			segments := classify(t, tc.s, format)
+			if len(segments) != 1 || segments[0].Class != tc.class || segments[0].Confidence < 0.5 {
+				t.Errorf("%s format %d: unexpected segments: %v", tc.name, format, segments)
+			}
+		}
+	}
+}
+
+// Test the segments of a call that goes from speech to hold music and silence
+func TestSegments(t *testing.T) {
+	var s []int16
+	s = append(s, speech(t)[:2*8192]...)
+	s = append(s, music(4*1024, 2)...)
+	s = append(s, make([]int16, 2*8192)...)
+	segments, err := Classify(bytes.NewReader(pcm.Encode(g711.Ulaw, s, nil)), g711.Ulaw)
+	if err != nil {
+		t.Fatalf("Classify failed: %s\n", err)
+	}
+	want := []Segment{
+		{Class: Speech, Start: 0, End: 2 * 8192},
+		{Class: Music, Start: 2 * 8192, End: 6 * 8192},
+		{Class: Silence, Start: 6 * 8192, End: 8 * 8192},
+	}
+	if len(segments) != len(want) {
+		t.Fatalf("Unexpected segments: %v", segments)
+	}
+	for i, w := range want {
+		g := segments[i]
+		if g.Class != w.Class || g.Start != w.Start || g.End != w.End {
+			t.Errorf("Segment %d: %v, expected %v", i, g, w)
+		}
+	}
+	if _, err := Classify(bytes.NewReader(nil), 5); err == nil {
+		t.Error("Invalid format accepted")
+	}
+}
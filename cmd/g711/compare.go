@@ -0,0 +1,43 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zaf/g711/metrics"
+)
+
+// compare prints the quality metrics between a reference and a test file
+func compare(args []string) error {
+	if len(args) != 2 {
+		return errors.New("compare takes a reference and a test file")
+	}
+	ref, refFormat, err := openFile(args[0])
+	if err != nil {
+		return err
+	}
+	defer ref.Close()
+	test, testFormat, err := openFile(args[1])
+	if err != nil {
+		return err
+	}
+	defer test.Close()
+	r, err := metrics.Compare(ref, refFormat, test, testFormat)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Samples:        %d\n", r.Samples)
+	fmt.Printf("SNR:            %.2f dB\n", r.SNR)
+	fmt.Printf("Segmental SNR:  %.2f dB\n", r.SegSNR)
+	fmt.Printf("MSE:            %.2f\n", r.MSE)
+	fmt.Printf("Max abs error:  %d\n", r.MaxAbsError)
+	return nil
+}
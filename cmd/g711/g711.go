@@ -0,0 +1,116 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+
+	g711 is a collection of tools for G711 and LPCM sound data.
+	The file format is recognised from the file extension.
+
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zaf/g711"
+)
+
+const wavHeader = 44
+
+// command is a g711 sub-command
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"compare", "[reference file] [test file]\n\tPrints SNR, segmental SNR, MSE and max absolute error between two files", compare},
+	{"gen", "[signal] [flags] [output file]\n\tGenerates a signal: sine, dual, dtmf, dial, ringback, busy, congestion, white, pink or silence\n" +
+		"\tFlags: -f frequencies, -l level in dBm0, -d duration in ms, -digits DTMF digits,\n" +
+		"\t-tone and -pause DTMF durations in ms, -c country, -seed noise seed", gen},
+	{"level", "[input file]\n\tPrints the P.56 active speech level, RMS and peak level and activity factor", level},
+	{"normalize", "[-t target] [input file] [output file]\n\tRewrites a file at a target active speech level in dBov, -26 by default", normalize},
+	{"split", "--vad [-s silence] [-p padding] [-m max length] [input file] [output file]\n" +
+		"\tSplits a file into numbered output files of one utterance each.\n" +
+		"\tDurations in ms: -s silence that ends an utterance, -p padding, -m longest utterance", split},
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "--help" {
+		usage()
+		os.Exit(1)
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+	}
+	usage()
+	os.Exit(1)
+}
+
+func usage() {
+	fmt.Printf("%s is a collection of tools for G711 and LPCM sound data\n", os.Args[0])
+	fmt.Println("Files are recognised by their extension: .alaw/.al for A-law, .ulaw/.ul for u-law")
+	fmt.Println("and .raw/.sln/.wav for 16bit 8kHz LPCM.")
+	fmt.Printf("\nUsage: %s [command] [arguments]\n\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Printf("%s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// fileFormat returns the data format of a file based on its extension
+func fileFormat(file string) (int, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".alaw", ".al":
+		return g711.Alaw, nil
+	case ".ulaw", ".ul":
+		return g711.Ulaw, nil
+	case ".raw", ".sln", ".wav":
+		return g711.Lpcm, nil
+	}
+	return 0, fmt.Errorf("unrecognised format for file: %s", file)
+}
+
+// openFile opens a file for reading, skips any wav header and returns its data format
+func openFile(file string) (*os.File, int, error) {
+	format, err := fileFormat(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	input, err := os.Open(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	if strings.ToLower(filepath.Ext(file)) == ".wav" {
+		input.Seek(wavHeader, 0) // Skip wav header
+	}
+	return input, format, nil
+}
+
+// createFile creates a file for writing and returns its data format
+func createFile(file string) (*os.File, int, error) {
+	if strings.ToLower(filepath.Ext(file)) == ".wav" {
+		return nil, 0, fmt.Errorf("unsupported output format for file: %s", file)
+	}
+	format, err := fileFormat(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	output, err := os.Create(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	return output, format, nil
+}
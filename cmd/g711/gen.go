@@ -0,0 +1,85 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zaf/g711/generator"
+)
+
+// gen writes a generated signal to a file
+func gen(args []string) error {
+	if len(args) < 1 {
+		return errors.New("gen takes a signal and an output file")
+	}
+	name := args[0]
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	freqs := flags.String("f", "1020", "comma separated frequencies in Hz")
+	level := flags.Float64("l", -10, "level in dBm0, per frequency for tones")
+	duration := flags.Int("d", 1000, "duration in ms")
+	digits := flags.String("digits", "", "DTMF digits")
+	tone := flags.Int("tone", 80, "DTMF tone duration in ms")
+	pause := flags.Int("pause", 80, "DTMF pause duration in ms")
+	country := flags.String("c", "us", "country of the call progress tones")
+	seed := flags.Int64("seed", 1, "noise generator seed")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("gen takes a signal and an output file")
+	}
+	var signal generator.Signal
+	var err error
+	switch name {
+	case "sine", "dual":
+		var f []float64
+		for _, s := range strings.Split(*freqs, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return err
+			}
+			f = append(f, v)
+		}
+		if (name == "sine" && len(f) != 1) || (name == "dual" && len(f) != 2) {
+			return errors.New("wrong number of frequencies")
+		}
+		signal = generator.Tone(f, *level, *duration)
+	case "dtmf":
+		signal, err = generator.DTMF(*digits, *level, *tone, *pause)
+	case "dial", "ringback", "busy", "congestion":
+		signal, err = generator.CallProgress(*country, name, *duration)
+	case "white":
+		signal = generator.WhiteNoise(*level, *duration, *seed)
+	case "pink":
+		signal = generator.PinkNoise(*level, *duration, *seed)
+	case "silence":
+		signal = generator.Silence(*duration)
+	default:
+		err = errors.New("unknown signal: " + name)
+	}
+	if err != nil {
+		return err
+	}
+	output, format, err := createFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	reader, err := generator.NewReader(signal, format)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(output, reader)
+	return err
+}
@@ -0,0 +1,79 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/zaf/g711/p56"
+)
+
+// level prints the P.56 active speech level of a file
+func level(args []string) error {
+	if len(args) != 1 {
+		return errors.New("level takes an input file")
+	}
+	input, format, err := openFile(args[0])
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	r, err := p56.Measure(input, format)
+	if err != nil {
+		return err
+	}
+	printLevel(r)
+	return nil
+}
+
+// normalize rewrites a file to a target active speech level
+func normalize(args []string) error {
+	flags := flag.NewFlagSet("normalize", flag.ContinueOnError)
+	target := flags.Float64("t", p56.Target, "target active speech level in dBov")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("normalize takes an input and an output file")
+	}
+	input, format, err := openFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	outFormat, err := fileFormat(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if outFormat != format {
+		return errors.New("input and output files must have the same format")
+	}
+	output, _, err := createFile(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	r, err := p56.Normalize(output, input, format, *target)
+	if err != nil {
+		return err
+	}
+	printLevel(r)
+	fmt.Printf("Gain:           %.2f dB\n", *target-r.Level)
+	return nil
+}
+
+func printLevel(r p56.Result) {
+	fmt.Printf("Samples:        %d\n", r.Samples)
+	fmt.Printf("Active level:   %.2f dBov\n", r.Level)
+	fmt.Printf("RMS level:      %.2f dBov\n", r.RMS)
+	fmt.Printf("Peak level:     %.2f dBov\n", r.Peak)
+	fmt.Printf("Activity:       %.1f%%\n", 100*r.Activity)
+}
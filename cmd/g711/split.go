@@ -0,0 +1,70 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/utterance"
+)
+
+// split writes the utterances of a file to numbered files
+func split(args []string) error {
+	flags := flag.NewFlagSet("split", flag.ContinueOnError)
+	vad := flags.Bool("vad", false, "split into utterances")
+	silence := flags.Int("s", utterance.DefaultConfig.MinSilence, "silence that ends an utterance in ms")
+	padding := flags.Int("p", utterance.DefaultConfig.Padding, "audio kept around an utterance in ms")
+	maxLength := flags.Int("m", utterance.DefaultConfig.MaxLength, "longest utterance in ms")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*vad {
+		return errors.New("split requires --vad")
+	}
+	if flags.NArg() != 2 {
+		return errors.New("split takes an input and an output file")
+	}
+	input, format, err := openFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	outFormat, err := fileFormat(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if outFormat != format {
+		return errors.New("input and output files must have the same format")
+	}
+	ext := filepath.Ext(flags.Arg(1))
+	base := strings.TrimSuffix(flags.Arg(1), ext)
+	count := 0
+	save := func(u utterance.Utterance) error {
+		count++
+		name := fmt.Sprintf("%s-%03d%s", base, count, ext)
+		output, _, err := createFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := output.Write(u.Data); err != nil {
+			output.Close()
+			return err
+		}
+		fmt.Printf("%s: %.3f - %.3f s\n", name, float64(u.Start)/dsp.SampleRate, float64(u.End)/dsp.SampleRate)
+		return output.Close()
+	}
+	c := utterance.DefaultConfig
+	c.MinSilence, c.Padding, c.MaxLength = *silence, *padding, *maxLength
+	return utterance.Split(input, format, c, save)
+}
@@ -0,0 +1,139 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package conformance verifies the g711 codec against the behaviour of the g711
+module of the ITU-T Software Tool Library (G.191).
+
+G.191 processes A-law with 13bit and u-law with 14bit linear samples, left justified
+in a 16bit word. The package provides conversions between those linear conventions
+and the 16bit LPCM used by g711, along with reference vectors generated from the
+G.191 formulas that are embedded in the package.
+*/
+package conformance
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/zaf/g711"
+)
+
+//go:generate go run gen.go
+
+const (
+	// Ranges of the G.191 linear conventions
+	Min13 = -4096 // Smallest 13bit linear value
+	Max13 = 4095  // Largest 13bit linear value
+	Min14 = -8192 // Smallest 14bit linear value
+	Max14 = 8191  // Largest 14bit linear value
+)
+
+var (
+	// A-law codes for every 13bit linear value, from Min13 to Max13
+	//go:embed vectors/alaw_compress.bin
+	alawCompress []byte
+	// 13bit linear values for every A-law code, 16bit little endian
+	//go:embed vectors/alaw_expand.bin
+	alawExpand []byte
+	// u-law codes for every 14bit linear value, from Min14 to Max14
+	//go:embed vectors/ulaw_compress.bin
+	ulawCompress []byte
+	// 14bit linear values for every u-law code, 16bit little endian
+	//go:embed vectors/ulaw_expand.bin
+	ulawExpand []byte
+)
+
+// Linear13 converts a 16bit LPCM sample to a right justified 13bit linear value
+func Linear13(frame int16) int16 {
+	return frame >> 3
+}
+
+// Linear14 converts a 16bit LPCM sample to a right justified 14bit linear value
+func Linear14(frame int16) int16 {
+	return frame >> 2
+}
+
+// Lpcm13 converts a 13bit linear value to a left justified 16bit LPCM sample
+func Lpcm13(linear int16) int16 {
+	return clip(linear, Min13, Max13) << 3
+}
+
+// Lpcm14 converts a 14bit linear value to a left justified 16bit LPCM sample
+func Lpcm14(linear int16) int16 {
+	return clip(linear, Min14, Max14) << 2
+}
+
+// Linear13ToAlaw returns the reference A-law code of a 13bit linear value.
+// Values outside the 13bit range are clipped.
+func Linear13ToAlaw(linear int16) uint8 {
+	return alawCompress[int(clip(linear, Min13, Max13))-Min13]
+}
+
+// AlawToLinear13 returns the reference 13bit linear value of an A-law code
+func AlawToLinear13(code uint8) int16 {
+	return int16(binary.LittleEndian.Uint16(alawExpand[int(code)*2:]))
+}
+
+// Linear14ToUlaw returns the reference u-law code of a 14bit linear value.
+// Values outside the 14bit range are clipped.
+func Linear14ToUlaw(linear int16) uint8 {
+	return ulawCompress[int(clip(linear, Min14, Max14))-Min14]
+}
+
+// UlawToLinear14 returns the reference 14bit linear value of a u-law code
+func UlawToLinear14(code uint8) int16 {
+	return int16(binary.LittleEndian.Uint16(ulawExpand[int(code)*2:]))
+}
+
+// VerifyAlaw sweeps all 65536 LPCM inputs and all 256 A-law codes through the g711
+// A-law encoder and decoder and reports the first deviation from the reference vectors.
+func VerifyAlaw() error {
+	for i := -32768; i <= 32767; i++ {
+		frame := int16(i)
+		if code, ref := g711.EncodeAlawFrame(frame), Linear13ToAlaw(Linear13(frame)); code != ref {
+			return fmt.Errorf("A-law encoding of %d: got 0x%02x, reference 0x%02x", frame, code, ref)
+		}
+	}
+	for i := 0; i < 256; i++ {
+		code := uint8(i)
+		if frame, ref := g711.DecodeAlawFrame(code), Lpcm13(AlawToLinear13(code)); frame != ref {
+			return fmt.Errorf("A-law decoding of 0x%02x: got %d, reference %d", code, frame, ref)
+		}
+	}
+	return nil
+}
+
+// VerifyUlaw sweeps all 65536 LPCM inputs and all 256 u-law codes through the g711
+// u-law encoder and decoder and reports the first deviation from the reference vectors.
+func VerifyUlaw() error {
+	for i := -32768; i <= 32767; i++ {
+		frame := int16(i)
+		if code, ref := g711.EncodeUlawFrame(frame), Linear14ToUlaw(Linear14(frame)); code != ref {
+			return fmt.Errorf("u-law encoding of %d: got 0x%02x, reference 0x%02x", frame, code, ref)
+		}
+	}
+	for i := 0; i < 256; i++ {
+		code := uint8(i)
+		if frame, ref := g711.DecodeUlawFrame(code), Lpcm14(UlawToLinear14(code)); frame != ref {
+			return fmt.Errorf("u-law decoding of 0x%02x: got %d, reference %d", code, frame, ref)
+		}
+	}
+	return nil
+}
+
+func clip(x, lo, hi int16) int16 {
+	if x < lo {
+		return lo
+	}
+	if x > hi {
+		return hi
+	}
+	return x
+}
@@ -0,0 +1,61 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package conformance
+
+import "testing"
+
+// Test the A-law codec bit for bit against the reference vectors
+func TestAlaw(t *testing.T) {
+	if err := VerifyAlaw(); err != nil {
+		t.Error(err)
+	}
+}
+
+// Test the u-law codec bit for bit against the reference vectors
+func TestUlaw(t *testing.T) {
+	if err := VerifyUlaw(); err != nil {
+		t.Error(err)
+	}
+}
+
+// Test the reference vectors at the G.711 table boundaries
+func TestVectors(t *testing.T) {
+	var alaw = []struct {
+		linear int16
+		code   uint8
+	}{
+		{0, 0xD5}, {1, 0xD5}, {-1, 0x55}, {31, 0xDA}, {32, 0xC5},
+		{Max13, 0xAA}, {Min13, 0x2A},
+	}
+	for _, tc := range alaw {
+		if code := Linear13ToAlaw(tc.linear); code != tc.code {
+			t.Errorf("Linear13ToAlaw(%d): expected: 0x%02x, actual: 0x%02x", tc.linear, tc.code, code)
+		}
+	}
+	var ulaw = []struct {
+		linear int16
+		code   uint8
+	}{
+		{0, 0xFF}, {-1, 0x7F}, {30, 0xF0}, {31, 0xEF}, {Max14, 0x80}, {Min14, 0x00},
+	}
+	for _, tc := range ulaw {
+		if code := Linear14ToUlaw(tc.linear); code != tc.code {
+			t.Errorf("Linear14ToUlaw(%d): expected: 0x%02x, actual: 0x%02x", tc.linear, tc.code, code)
+		}
+	}
+	for i := 0; i < 256; i++ {
+		code := uint8(i)
+		if Linear13ToAlaw(AlawToLinear13(code)) != code {
+			t.Errorf("A-law code 0x%02x does not survive a round trip", code)
+		}
+		if UlawToLinear14(code) != 0 && Linear14ToUlaw(UlawToLinear14(code)) != code {
+			t.Errorf("u-law code 0x%02x does not survive a round trip", code)
+		}
+	}
+}
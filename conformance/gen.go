@@ -0,0 +1,126 @@
+//go:build ignore
+
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+
+	gen generates the reference vectors of the conformance package.
+	It is a direct port of alaw_compress, alaw_expand, ulaw_compress
+	and ulaw_expand from the g711 module of ITU-T G.191.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	var alawCompress, ulawCompress, alawExpand, ulawExpand []byte
+	for x := -4096; x <= 4095; x++ {
+		alawCompress = append(alawCompress, alawCompressG191(int16(x<<3)))
+	}
+	for x := -8192; x <= 8191; x++ {
+		ulawCompress = append(ulawCompress, ulawCompressG191(int16(x<<2)))
+	}
+	for code := 0; code < 256; code++ {
+		alawExpand = binary.LittleEndian.AppendUint16(alawExpand, uint16(alawExpandG191(uint8(code))>>3))
+		ulawExpand = binary.LittleEndian.AppendUint16(ulawExpand, uint16(ulawExpandG191(uint8(code))>>2))
+	}
+	write("alaw_compress.bin", alawCompress)
+	write("alaw_expand.bin", alawExpand)
+	write("ulaw_compress.bin", ulawCompress)
+	write("ulaw_expand.bin", ulawExpand)
+}
+
+func write(name string, data []byte) {
+	if err := os.WriteFile(filepath.Join("vectors", name), data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// A-law compression of a 13bit sample, left justified in 16bit
+func alawCompressG191(lin int16) uint8 {
+	var ix int16
+	if lin < 0 {
+		ix = (^lin) >> 4 // 1's complement for negative values
+	} else {
+		ix = lin >> 4
+	}
+	if ix > 15 { // exponent=0 for ix <= 15
+		iexp := int16(1)
+		for ix > 16+15 { // find mantissa and exponent
+			ix >>= 1
+			iexp++
+		}
+		ix -= 16        // remove leading '1'
+		ix += iexp << 4 // compute encoded value
+	}
+	if lin >= 0 {
+		ix |= 0x0080 // add sign bit
+	}
+	return uint8(ix ^ 0x0055) // toggle even bits
+}
+
+// A-law expansion to a 13bit sample, left justified in 16bit
+func alawExpandG191(log uint8) int16 {
+	ix := int16(log) ^ 0x0055 // re-toggle toggled bits
+	ix &= 0x007F              // remove sign bit
+	iexp := ix >> 4           // extract exponent
+	mant := ix & 0x000F       // get mantissa
+	if iexp > 0 {
+		mant += 16 // add leading '1', if exponent > 0
+	}
+	mant = (mant << 4) + 0x0008 // left justify mantissa and add 1/2 quantization step
+	if iexp > 1 {
+		mant <<= iexp - 1
+	}
+	if log > 127 {
+		return mant
+	}
+	return -mant
+}
+
+// u-law compression of a 14bit sample, left justified in 16bit
+func ulawCompressG191(lin int16) uint8 {
+	var absno int16
+	if lin < 0 {
+		absno = ((^lin) >> 2) + 33
+	} else {
+		absno = (lin >> 2) + 33
+	}
+	if absno > 0x1FFF {
+		absno = 0x1FFF
+	}
+	segno := int16(1)
+	for i := absno >> 6; i != 0; i >>= 1 {
+		segno++
+	}
+	highNibble := 0x0008 - segno
+	lowNibble := 0x000F - ((absno >> segno) & 0x000F)
+	out := (highNibble << 4) | lowNibble
+	if lin >= 0 {
+		out |= 0x0080
+	}
+	return uint8(out)
+}
+
+// u-law expansion to a 14bit sample, left justified in 16bit
+func ulawExpandG191(log uint8) int16 {
+	sign := int16(1)
+	if log < 0x80 {
+		sign = -1
+	}
+	mantissa := ^int16(log)
+	exponent := (mantissa >> 4) & 0x0007
+	segment := exponent + 1
+	mantissa &= 0x000F
+	step := int16(4) << segment
+	return sign * ((0x0080 << exponent) + step*mantissa + step/2 - 4*33)
+}
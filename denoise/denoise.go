@@ -0,0 +1,318 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package denoise suppresses stationary background noise in 8kHz G711 or LPCM audio
+by spectral subtraction or Wiener filtering.
+
+The audio is analysed in 32ms frames with 50% overlap. The noise spectrum is
+tracked from the minima of the smoothed signal spectrum and every frequency bin
+is attenuated according to its estimated signal to noise ratio, down to the
+configured suppression depth. The output lags the input by Delay samples.
+
+A Suppressor can decode and re-encode, taking one format and producing another,
+and can wrap an io.Reader, such as a g711 Decoder, or an io.Writer, such as a
+g711 Encoder.
+*/
+package denoise
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// Method is the suppression rule
+type Method int
+
+const (
+	// Methods
+	Wiener              Method = iota // Wiener filter with decision directed SNR estimation
+	SpectralSubtraction               // Power spectral subtraction
+)
+
+const (
+	frameSize = 256 // Analysis frame, 32ms
+	hop       = frameSize / 2
+	bins      = frameSize/2 + 1
+	// Delay is the latency of the Suppressor in samples
+	Delay      = frameSize - hop
+	initFrames = 8    // Frames averaged for the initial noise estimate
+	smoothing  = 0.8  // Spectrum smoothing for the noise tracking
+	noiseRise  = 1.01 // Noise estimate rise factor per frame, about 3dB per second
+	bias       = 2    // Compensation of the minimum tracking underestimate
+	decision   = 0.9  // Weight of the previous frame in the a priori SNR
+	oversub    = 2    // Noise overestimation of spectral subtraction
+)
+
+// Config holds the Suppressor parameters
+type Config struct {
+	Method Method
+	Depth  float64 // Largest attenuation in dB
+}
+
+// DefaultConfig is a Wiener filter with 15dB of suppression
+var DefaultConfig = Config{Method: Wiener, Depth: 15}
+
+// Suppressor is a noise suppressor
+type Suppressor struct {
+	input, output int // data formats
+	config        Config
+	floor         float64 // smallest gain
+	window        [frameSize]float64
+	frame         [frameSize]float64 // last input frame
+	overlap       [frameSize]float64 // overlap-add accumulator
+	spectrum      []complex128
+	smooth        [bins]float64 // smoothed power spectrum
+	noise         [bins]float64 // noise power spectrum
+	prior         [bins]float64 // clean power estimate of the previous frame
+	frames        int           // frames analysed
+	skip          int           // leading output samples to drop
+	in, emitted   int64         // samples taken and produced
+	framer        dsp.Framer
+	samples       []int16
+	out           []int16
+}
+
+// New returns a pointer to a Suppressor that takes data in the input format
+// and produces data in the output format
+func New(input, output int, c Config) (*Suppressor, error) {
+	if !pcm.Valid(input) {
+		return nil, errors.New("invalid input format")
+	}
+	if !pcm.Valid(output) {
+		return nil, errors.New("invalid output format")
+	}
+	if c.Depth < 0 || (c.Method != Wiener && c.Method != SpectralSubtraction) {
+		return nil, errors.New("invalid configuration")
+	}
+	s := &Suppressor{
+		input:    input,
+		output:   output,
+		config:   c,
+		floor:    math.Pow(10, -c.Depth/20),
+		spectrum: make([]complex128, frameSize),
+		skip:     Delay,
+		framer:   dsp.Framer{Size: hop},
+	}
+	// Square root periodic Hann, which sums to unity over analysis and synthesis at 50% overlap
+	for i := range s.window {
+		s.window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/frameSize))
+	}
+	return s, nil
+}
+
+// Process suppresses the noise of a frame of audio data and returns the processed
+// data available, Delay samples behind the input.
+func (s *Suppressor) Process(frame []byte) []byte {
+	s.samples = pcm.Decode(s.input, frame, s.samples[:0])
+	return pcm.Encode(s.output, s.ProcessSamples(s.samples), nil)
+}
+
+// ProcessSamples suppresses the noise of 16bit linear samples and returns the
+// processed samples available, Delay samples behind the input. The returned
+// slice is valid until the next call.
+func (s *Suppressor) ProcessSamples(x []int16) []int16 {
+	s.out = s.out[:0]
+	s.in += int64(len(x))
+	s.framer.Push(x, s.block)
+	s.emitted += int64(len(s.out))
+	return s.out
+}
+
+// Flush ends the stream and returns the processed samples still held in the Suppressor
+func (s *Suppressor) Flush() []int16 {
+	s.out = s.out[:0]
+	s.framer.Push(make([]int16, Delay+hop), s.block)
+	s.out = s.out[:s.in-s.emitted]
+	s.emitted = s.in
+	return s.out
+}
+
+// Reset discards the Suppressor state. This permits reusing a Suppressor rather than allocating a new one.
+func (s *Suppressor) Reset() {
+	s.frame, s.overlap = [frameSize]float64{}, [frameSize]float64{}
+	s.smooth, s.noise, s.prior = [bins]float64{}, [bins]float64{}, [bins]float64{}
+	s.frames, s.skip, s.in, s.emitted = 0, Delay, 0, 0
+	s.framer.Reset()
+}
+
+// block processes a hop of new samples
+func (s *Suppressor) block(x []float64) {
+	copy(s.frame[:], s.frame[hop:])
+	copy(s.frame[hop:], x)
+	for i, v := range s.frame {
+		s.spectrum[i] = complex(v*s.window[i], 0)
+	}
+	dsp.FFT(s.spectrum)
+	s.frames++
+	for k := 0; k < bins; k++ {
+		c := s.spectrum[k]
+		p := real(c)*real(c) + imag(c)*imag(c)
+		g := s.gain(k, p)
+		s.spectrum[k] *= complex(g, 0)
+		if k > 0 && k < frameSize/2 {
+			s.spectrum[frameSize-k] = complex(real(s.spectrum[k]), -imag(s.spectrum[k]))
+		}
+	}
+	dsp.IFFT(s.spectrum)
+	for i := range s.overlap {
+		s.overlap[i] += real(s.spectrum[i]) * s.window[i]
+	}
+	for _, v := range s.overlap[:hop] {
+		if s.skip > 0 {
+			s.skip--
+			continue
+		}
+		s.out = append(s.out, clip(v))
+	}
+	copy(s.overlap[:], s.overlap[hop:])
+	for i := hop; i < frameSize; i++ {
+		s.overlap[i] = 0
+	}
+}
+
+// gain updates the noise estimate of bin k with its power p and returns its gain
+func (s *Suppressor) gain(k int, p float64) float64 {
+	switch {
+	case s.frames == 1:
+		s.smooth[k], s.noise[k] = p, p
+	case s.frames <= initFrames:
+		s.smooth[k] = smoothing*s.smooth[k] + (1-smoothing)*p
+		s.noise[k] += (p - s.noise[k]) / float64(s.frames)
+	default:
+		s.smooth[k] = smoothing*s.smooth[k] + (1-smoothing)*p
+		s.noise[k] = math.Min(s.smooth[k]*bias, s.noise[k]*noiseRise)
+	}
+	if s.noise[k] <= 0 || p <= 0 {
+		return 1
+	}
+	var g float64
+	if s.config.Method == SpectralSubtraction {
+		g = math.Sqrt(math.Max(0, 1-oversub*s.noise[k]/p))
+	} else {
+		post := p / s.noise[k]
+		prio := decision*s.prior[k]/s.noise[k] + (1-decision)*math.Max(post-1, 0)
+		g = prio / (1 + prio)
+	}
+	g = math.Max(g, s.floor)
+	s.prior[k] = g * g * p
+	return g
+}
+
+func clip(x float64) int16 {
+	x = math.Round(x)
+	if x > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(x)
+}
+
+// Reader suppresses the noise of the data read from an io.Reader
+type Reader struct {
+	s       *Suppressor
+	source  *pcm.Reader
+	samples []int16
+	out     []int16 // processed samples not yet read
+	eof     bool
+}
+
+// NewReader returns a pointer to a Reader that suppresses the noise of data read
+// from r in the input format of the Suppressor and produces its output format
+func NewReader(r io.Reader, s *Suppressor) (*Reader, error) {
+	if s == nil {
+		return nil, errors.New("suppressor is nil")
+	}
+	source, err := pcm.NewReader(r, s.input)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{s: s, source: source}, nil
+}
+
+// Read reads data from the source and suppresses its noise. It returns
+// the number of bytes read and any error encountered.
+func (r *Reader) Read(p []byte) (int, error) {
+	size := pcm.Size(r.s.output)
+	n := len(p) / size
+	if n == 0 {
+		return 0, nil
+	}
+	for len(r.out) < n && !r.eof {
+		if cap(r.samples) < n {
+			r.samples = make([]int16, n)
+		}
+		m, err := r.source.Read(r.samples[:n])
+		r.out = append(r.out, r.s.ProcessSamples(r.samples[:m])...)
+		if err == io.EOF {
+			r.out = append(r.out, r.s.Flush()...)
+			r.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	if len(r.out) == 0 {
+		return 0, io.EOF
+	}
+	if n > len(r.out) {
+		n = len(r.out)
+	}
+	pcm.Encode(r.s.output, r.out[:n], p[:0])
+	r.out = r.out[:copy(r.out, r.out[n:])]
+	return n * size, nil
+}
+
+// Writer suppresses the noise of the data written to an io.Writer
+type Writer struct {
+	s       *Suppressor
+	dest    io.Writer
+	pending []byte // input bytes of an incomplete sample
+	samples []int16
+	buf     []byte
+}
+
+// NewWriter returns a pointer to a Writer that suppresses the noise of data in the
+// input format of the Suppressor and writes its output format to w.
+// Close flushes the samples held in the Suppressor.
+func NewWriter(w io.Writer, s *Suppressor) (*Writer, error) {
+	if w == nil {
+		return nil, errors.New("io.Writer is nil")
+	}
+	if s == nil {
+		return nil, errors.New("suppressor is nil")
+	}
+	return &Writer{s: s, dest: w}, nil
+}
+
+// Write suppresses the noise of the contents of p and writes the processed data
+// available to the destination. It returns the number of bytes consumed and any error encountered.
+func (w *Writer) Write(p []byte) (int, error) {
+	data := append(w.pending, p...)
+	size := pcm.Size(w.s.input)
+	whole := len(data) / size * size
+	w.samples = pcm.Decode(w.s.input, data[:whole], w.samples[:0])
+	w.pending = append(w.pending[:0], data[whole:]...)
+	w.buf = pcm.Encode(w.s.output, w.s.ProcessSamples(w.samples), w.buf[:0])
+	if _, err := w.dest.Write(w.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close writes the samples held in the Suppressor to the destination
+func (w *Writer) Close() error {
+	w.buf = pcm.Encode(w.s.output, w.s.Flush(), w.buf[:0])
+	_, err := w.dest.Write(w.buf)
+	return err
+}
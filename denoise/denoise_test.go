@@ -0,0 +1,119 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package denoise
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/pcm"
+	"github.com/zaf/g711/metrics"
+)
+
+// noisy returns the test speech after a second of silence, and the same with white noise added
+func noisy(t *testing.T, rms float64) (clean, noisy []int16) {
+	data, err := os.ReadFile("../testing/speech.raw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	clean = append(make([]int16, 8000), pcm.Decode(g711.Lpcm, data, nil)...)
+	rng := rand.New(rand.NewSource(1))
+	for _, x := range clean {
+		noisy = append(noisy, clip(float64(x)+rms*rng.NormFloat64()))
+	}
+	return clean, noisy
+}
+
+// process runs samples through a new Suppressor in chunks of 100 samples
+func process(t *testing.T, c Config, x []int16) []int16 {
+	s, err := New(g711.Lpcm, g711.Lpcm, c)
+	if err != nil {
+		t.Fatalf("Failed to create Suppressor: %s\n", err)
+	}
+	var out []int16
+	for i := 0; i < len(x); i += 100 {
+		out = append(out, s.ProcessSamples(x[i:int(math.Min(float64(i+100), float64(len(x))))])...)
+	}
+	return append(out, s.Flush()...)
+}
+
+func level(s []int16) float64 {
+	var p float64
+	for _, x := range s {
+		p += float64(x) * float64(x)
+	}
+	return 10 * math.Log10(p/float64(len(s)))
+}
+
+// Test that the analysis and synthesis reconstruct the input without suppression
+func TestReconstruction(t *testing.T) {
+	_, x := noisy(t, 300)
+	out := process(t, Config{Method: Wiener, Depth: 0}, x)
+	if len(out) != len(x) {
+		t.Fatalf("Output length %d, expected %d", len(out), len(x))
+	}
+	for i := range x {
+		if d := int(out[i]) - int(x[i]); d > 1 || d < -1 {
+			t.Fatalf("Sample %d: expected: %d, actual: %d", i, x[i], out[i])
+		}
+	}
+}
+
+// Test the noise reduction and the speech quality of both methods
+func TestSuppression(t *testing.T) {
+	clean, x := noisy(t, 300)
+	for _, m := range []Method{Wiener, SpectralSubtraction} {
+		c := Config{Method: m, Depth: 15}
+		out := process(t, c, x)
+		// Noise only part, after the initial estimate
+		if r := level(x[4000:8000]) - level(out[4000:8000]); r < 6 {
+			t.Errorf("Method %d: noise reduced by %.1f dB, expected at least 6 dB", m, r)
+		}
+		before, _ := metrics.NewAccumulator(metrics.SegmentSize)
+		after, _ := metrics.NewAccumulator(metrics.SegmentSize)
+		before.Add(clean[8000:], x[8000:])
+		after.Add(clean[8000:], out[8000:])
+		if b, a := before.Result().SegSNR, after.Result().SegSNR; a < b+1.5 {
+			t.Errorf("Method %d: segmental SNR %.1f dB, from %.1f dB", m, a, b)
+		}
+	}
+}
+
+// Test the Reader and Writer with re-encoding to A-law
+func TestReaderWriter(t *testing.T) {
+	_, x := noisy(t, 300)
+	data := pcm.Encode(g711.Lpcm, x, nil)
+	s, _ := New(g711.Lpcm, g711.Alaw, DefaultConfig)
+	r, err := NewReader(bytes.NewReader(data), s)
+	if err != nil {
+		t.Fatalf("Failed to create Reader: %s\n", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || len(got) != len(x) {
+		t.Fatalf("Reader returned %d bytes, expected %d: %v", len(got), len(x), err)
+	}
+	s, _ = New(g711.Lpcm, g711.Alaw, DefaultConfig)
+	var b bytes.Buffer
+	w, err := NewWriter(&b, s)
+	if err != nil {
+		t.Fatalf("Failed to create Writer: %s\n", err)
+	}
+	for i := 0; i < len(data); i += 333 {
+		w.Write(data[i:int(math.Min(float64(i+333), float64(len(data))))])
+	}
+	w.Close()
+	if !bytes.Equal(b.Bytes(), got) {
+		t.Error("Writer output differs from Reader output")
+	}
+}
@@ -0,0 +1,174 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package dither reduces floating point and 24bit audio to the 16bit LPCM that
+the g711 Encoder takes, with optional TPDF dither and noise shaping.
+
+Plain rounding of quiet passages leaves a quantisation error that follows the
+signal and is heard as granular distortion. Triangular probability density
+function (TPDF) dither of two LSB peak to peak makes the error independent of
+the signal, a steady hiss instead. First order error feedback shapes that
+hiss towards high frequencies. The dither source is seeded, so the output is
+reproducible.
+*/
+package dither
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+)
+
+const (
+	// Input formats
+	Float32 = iota // Float32 32bit IEEE 754 little endian samples in the range [-1, 1)
+	Int24          // Int24 24bit signed little endian samples
+)
+
+const (
+	fullScale = 32768.0
+	maxError  = 2 // Largest fed back error in LSB, limits the feedback after clipping
+)
+
+// Config holds the quantiser parameters
+type Config struct {
+	Dither  bool  // Add TPDF dither
+	Shaping bool  // Shape the quantisation noise towards high frequencies
+	Seed    int64 // Seed of the dither source
+}
+
+// DefaultConfig applies TPDF dither without noise shaping
+var DefaultConfig = Config{Dither: true, Seed: 1}
+
+// Quantizer reduces samples to 16bit
+type Quantizer struct {
+	config Config
+	rng    *rand.Rand // dither source
+	err    float64    // last quantisation error, for noise shaping
+}
+
+// New returns a pointer to a Quantizer
+func New(c Config) *Quantizer {
+	return &Quantizer{config: c, rng: rand.New(rand.NewSource(c.Seed))}
+}
+
+// Quantize reduces a sample, scaled to the 16bit range, to a 16bit sample
+func (q *Quantizer) Quantize(x float64) int16 {
+	if q.config.Shaping {
+		x -= q.err
+	}
+	v := x
+	if q.config.Dither {
+		v += q.rng.Float64() - q.rng.Float64()
+	}
+	y := clip(v)
+	if q.config.Shaping {
+		q.err = math.Max(-maxError, math.Min(maxError, float64(y)-x))
+	}
+	return y
+}
+
+// Float reduces floating point samples in the range [-1, 1) and appends them to s
+func (q *Quantizer) Float(f []float64, s []int16) []int16 {
+	for _, x := range f {
+		s = append(s, q.Quantize(x*fullScale))
+	}
+	return s
+}
+
+// Int24 reduces 24bit signed little endian data and appends the samples to s.
+// A trailing incomplete sample is ignored.
+func (q *Quantizer) Int24(data []byte, s []int16) []int16 {
+	for i := 0; i+2 < len(data); i += 3 {
+		s = append(s, q.Quantize(float64(int24(data[i:]))/256))
+	}
+	return s
+}
+
+// Reset discards the Quantizer state and restarts the dither source from its seed.
+// This permits reusing a Quantizer rather than allocating a new one.
+func (q *Quantizer) Reset() {
+	q.rng.Seed(q.config.Seed)
+	q.err = 0
+}
+
+// int24 returns the 24bit signed little endian sample at the start of b
+func int24(b []byte) int32 {
+	return int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+}
+
+func clip(x float64) int16 {
+	x = math.Round(x)
+	if x > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(x)
+}
+
+// Writer reduces Float32 or Int24 data to 16bit LPCM and writes it to an io.Writer,
+// such as a g711 Encoder of LPCM input
+type Writer struct {
+	quantizer *Quantizer
+	input     int // input format
+	size      int // bytes per input sample
+	dest      io.Writer
+	samples   []int16
+	buf       []byte
+	pending   []byte // bytes of an incomplete sample
+}
+
+// NewWriter returns a pointer to a Writer that takes data in the input format
+// and writes 16bit LPCM to w
+func NewWriter(w io.Writer, input int, q *Quantizer) (*Writer, error) {
+	if w == nil {
+		return nil, errors.New("io.Writer is nil")
+	}
+	if q == nil {
+		return nil, errors.New("quantizer is nil")
+	}
+	var size int
+	switch input {
+	case Float32:
+		size = 4
+	case Int24:
+		size = 3
+	default:
+		return nil, errors.New("invalid input format")
+	}
+	return &Writer{quantizer: q, input: input, size: size, dest: w}, nil
+}
+
+// Write reduces the contents of p and writes them to the destination.
+// It returns the number of bytes consumed from p and any error encountered.
+func (w *Writer) Write(p []byte) (int, error) {
+	data := append(w.pending, p...)
+	whole := len(data) / w.size * w.size
+	w.samples = w.samples[:0]
+	if w.input == Int24 {
+		w.samples = w.quantizer.Int24(data[:whole], w.samples)
+	} else {
+		for i := 0; i < whole; i += 4 {
+			x := math.Float32frombits(uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24)
+			w.samples = append(w.samples, w.quantizer.Quantize(float64(x)*fullScale))
+		}
+	}
+	w.pending = append(w.pending[:0], data[whole:]...)
+	w.buf = w.buf[:0]
+	for _, x := range w.samples {
+		w.buf = append(w.buf, byte(x), byte(x>>8))
+	}
+	if _, err := w.dest.Write(w.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,143 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package dither
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// quiet returns n samples of a 1kHz sine wave of the given amplitude in LSB
+func quiet(amplitude float64, n int) []float64 {
+	f := make([]float64, n)
+	for i := range f {
+		f[i] = amplitude * math.Sin(2*math.Pi*1000*float64(i)/8000) / fullScale
+	}
+	return f
+}
+
+// Test plain rounding, reproducibility and the dither error statistics
+func TestDither(t *testing.T) {
+	in := quiet(0.4, 8000)
+	plain := New(Config{}).Float(in, nil)
+	for i, x := range plain {
+		if x != 0 {
+			t.Fatalf("Sample %d: %d, expected 0 without dither", i, x)
+		}
+	}
+	q := New(DefaultConfig)
+	a := q.Float(in, nil)
+	q.Reset()
+	if b := q.Float(in, nil); !equal(a, b) {
+		t.Error("Output differs after Reset")
+	}
+	if c := New(Config{Dither: true, Seed: 2}).Float(in, nil); equal(a, c) {
+		t.Error("Output identical with different seeds")
+	}
+	// The error of TPDF dither has zero mean, a variance of 1/4 LSB² and no correlation with the signal
+	var mean, variance, corr, sig float64
+	for i, x := range a {
+		e := float64(x) - in[i]*fullScale
+		mean += e
+		variance += e * e
+		corr += e * in[i] * fullScale
+		sig += in[i] * fullScale * in[i] * fullScale
+	}
+	n := float64(len(a))
+	mean /= n
+	variance /= n
+	if math.Abs(mean) > 0.02 || math.Abs(variance-0.25) > 0.02 {
+		t.Errorf("Dither error mean %.3f and variance %.3f, expected 0 and 0.25", mean, variance)
+	}
+	if r := corr / math.Sqrt(sig*variance*n); math.Abs(r) > 0.05 {
+		t.Errorf("Dither error correlates with the signal: %.3f", r)
+	}
+}
+
+// Test that noise shaping moves the quantisation noise towards high frequencies
+func TestShaping(t *testing.T) {
+	in := quiet(100, 8000)
+	low := func(c Config) float64 {
+		s := New(c).Float(in, nil)
+		// Error power through a first order low-pass
+		var y, p float64
+		for i, x := range s {
+			e := float64(x) - in[i]*fullScale
+			y += (e - y) * 0.1
+			p += y * y
+		}
+		return p
+	}
+	flat := low(Config{Dither: true, Seed: 1})
+	shaped := low(Config{Dither: true, Shaping: true, Seed: 1})
+	if d := 10 * math.Log10(flat/shaped); d < 3 {
+		t.Errorf("Low frequency noise reduced by %.1f dB, expected at least 3 dB", d)
+	}
+}
+
+// Test the Writer over odd chunks of both input formats
+func TestWriter(t *testing.T) {
+	in := quiet(5000, 800)
+	var f32, i24 []byte
+	for _, x := range in {
+		b := math.Float32bits(float32(x))
+		f32 = append(f32, byte(b), byte(b>>8), byte(b>>16), byte(b>>24))
+		v := int32(math.Round(x * fullScale * 256))
+		i24 = append(i24, byte(v), byte(v>>8), byte(v>>16))
+	}
+	for _, tc := range []struct {
+		input int
+		data  []byte
+	}{{Float32, f32}, {Int24, i24}} {
+		var out bytes.Buffer
+		w, err := NewWriter(&out, tc.input, New(Config{}))
+		if err != nil {
+			t.Fatalf("Failed to create Writer: %s\n", err)
+		}
+		for chunk := tc.data; len(chunk) > 0; {
+			n := 101
+			if n > len(chunk) {
+				n = len(chunk)
+			}
+			if k, err := w.Write(chunk[:n]); err != nil || k != n {
+				t.Fatalf("Write failed: %d %v\n", k, err)
+			}
+			chunk = chunk[n:]
+		}
+		if out.Len() != 2*len(in) {
+			t.Fatalf("Input %d: wrote %d bytes, expected %d", tc.input, out.Len(), 2*len(in))
+		}
+		b := out.Bytes()
+		for i, x := range in {
+			y := int16(b[2*i]) | int16(b[2*i+1])<<8
+			if y != int16(math.Round(x*fullScale)) {
+				t.Fatalf("Input %d sample %d: %d, expected %.0f", tc.input, i, y, math.Round(x*fullScale))
+			}
+		}
+	}
+	if s := New(Config{}).Int24([]byte{0x00, 0x00, 0x80, 0xff, 0xff, 0x7f}, nil); s[0] != math.MinInt16 || s[1] != math.MaxInt16 {
+		t.Errorf("Int24 full scale: %v", s)
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, 5, New(DefaultConfig)); err == nil {
+		t.Error("Invalid input format accepted")
+	}
+}
+
+func equal(a, b []int16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
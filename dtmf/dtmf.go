@@ -0,0 +1,216 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package dtmf detects in-band DTMF digits in G711 or LPCM audio.
+
+The detector runs Goertzel filters on blocks of 102 samples and applies the
+signal level, twist, frequency tolerance and duration rules of ITU-T Q.24.
+*/
+package dtmf
+
+import (
+	"errors"
+
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	blockSize = 102  // Samples per Goertzel block, 12.75ms
+	probe     = 0.04 // Relative offset of the frequency tolerance probes
+)
+
+var (
+	rows   = [4]float64{697, 770, 852, 941}
+	cols   = [4]float64{1209, 1336, 1477, 1633}
+	digits = [4][4]byte{
+		{'1', '2', '3', 'A'},
+		{'4', '5', '6', 'B'},
+		{'7', '8', '9', 'C'},
+		{'*', '0', '#', 'D'},
+	}
+)
+
+// Config holds the detection limits
+type Config struct {
+	MinLevel   float64 // Lowest level of each frequency in dBm0
+	LowTwist   float64 // Largest excess of the low group over the high group level in dB
+	HighTwist  float64 // Largest excess of the high group over the low group level in dB
+	MinTone    int     // Shortest tone to detect in ms
+	MinPause   int     // Shortest pause between digits in ms
+	PeakRatio  float64 // Least excess of the detected frequencies over the rest of their group in dB
+	ToneEnergy float64 // Least fraction of the signal energy in the two frequencies
+}
+
+// DefaultConfig holds the Q.24 limits for the North American region
+var DefaultConfig = Config{
+	MinLevel:   -36,
+	LowTwist:   8,
+	HighTwist:  4,
+	MinTone:    40,
+	MinPause:   40,
+	PeakRatio:  8,
+	ToneEnergy: 0.6,
+}
+
+// Event is a detected DTMF digit
+type Event struct {
+	Digit byte  // Digit, one of 0-9, *, #, A-D
+	Start int64 // Sample offset of the start of the tone
+	End   int64 // Sample offset of the end of the tone
+}
+
+// Detector detects DTMF digits in a stream of audio frames
+type Detector struct {
+	format     int     // input format
+	config     Config  // detection limits
+	minPower   float64 // Goertzel power at MinLevel
+	toneBlocks int     // blocks of a tone to detect a digit
+	rows, cols [4]dsp.Goertzel
+	probes     [2][4][2]dsp.Goertzel // frequency tolerance probes, below and above each frequency
+	framer     dsp.Framer
+	tracker    dsp.ToneTracker
+	samples    []int16 // decoding buffer
+	events     []Event
+}
+
+// NewDetector returns a pointer to a Detector for data in the given format
+func NewDetector(format int, config Config) (*Detector, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	if config.MinTone <= 0 || config.MinPause <= 0 {
+		return nil, errors.New("invalid duration")
+	}
+	d := &Detector{
+		format:     format,
+		config:     config,
+		minPower:   goertzelPower(dsp.Amplitude(config.MinLevel)),
+		toneBlocks: dsp.CompleteBlocks(config.MinTone, blockSize),
+		framer:     dsp.Framer{Size: blockSize},
+		tracker:    dsp.ToneTracker{Size: blockSize, Gap: dsp.CompleteBlocks(config.MinPause, blockSize)},
+	}
+	for i := range rows {
+		d.rows[i] = dsp.NewGoertzel(rows[i])
+		d.cols[i] = dsp.NewGoertzel(cols[i])
+		for j, f := range []float64{1 - probe, 1 + probe} {
+			d.probes[0][i][j] = dsp.NewGoertzel(rows[i] * f)
+			d.probes[1][i][j] = dsp.NewGoertzel(cols[i] * f)
+		}
+	}
+	return d, nil
+}
+
+// Process detects digits in a frame of audio data and returns the
+// digits that ended within it.
+func (d *Detector) Process(frame []byte) []Event {
+	d.samples = pcm.Decode(d.format, frame, d.samples[:0])
+	return d.ProcessSamples(d.samples)
+}
+
+// ProcessSamples detects digits in 16bit linear samples and returns the
+// digits that ended within them.
+func (d *Detector) ProcessSamples(s []int16) []Event {
+	d.events = d.events[:0]
+	d.framer.Push(s, d.block)
+	return d.events
+}
+
+// Flush ends the stream and returns any digit still active
+func (d *Detector) Flush() []Event {
+	d.events = d.events[:0]
+	if t, ok := d.tracker.Flush(); ok {
+		d.emit(t)
+	}
+	return d.events
+}
+
+// Reset discards the Detector state. This permits reusing a Detector rather than allocating a new one.
+func (d *Detector) Reset() {
+	d.framer.Reset()
+	d.tracker.Reset()
+}
+
+// block runs the detection on a single block
+func (d *Detector) block(s []float64) {
+	if t, ok := d.tracker.Push(d.classify(s), d.toneBlocks); ok {
+		d.emit(t)
+	}
+}
+
+// emit reports an ended digit
+func (d *Detector) emit(t dsp.ToneRun) {
+	d.events = append(d.events, Event{Digit: t.Class, Start: t.Start, End: t.End})
+}
+
+// classify returns the digit present in a block or 0
+func (d *Detector) classify(s []float64) byte {
+	var rowPower, colPower [4]float64
+	r, c := 0, 0
+	for i := range rows {
+		rowPower[i] = d.rows[i].Power(s)
+		colPower[i] = d.cols[i].Power(s)
+		if rowPower[i] > rowPower[r] {
+			r = i
+		}
+		if colPower[i] > colPower[c] {
+			c = i
+		}
+	}
+	row, col := rowPower[r], colPower[c]
+	// Signal level
+	if row < d.minPower || col < d.minPower {
+		return 0
+	}
+	// Twist
+	if row > col*dsp.DB(d.config.LowTwist) || col > row*dsp.DB(d.config.HighTwist) {
+		return 0
+	}
+	// Relative peaks within each group
+	peak := dsp.DB(d.config.PeakRatio)
+	for i := range rows {
+		if (i != r && rowPower[i]*peak > row) || (i != c && colPower[i]*peak > col) {
+			return 0
+		}
+	}
+	// Share of the total energy
+	if dsp.ToneEnergy(row+col, len(s)) < d.config.ToneEnergy*dsp.Energy(s) {
+		return 0
+	}
+	// Frequency tolerance, the nominal frequency must be closer to the tone than the probes
+	for _, p := range d.probes[0][r] {
+		if p.Power(s) > row {
+			return 0
+		}
+	}
+	for _, p := range d.probes[1][c] {
+		if p.Power(s) > col {
+			return 0
+		}
+	}
+	return digits[r][c]
+}
+
+// Frequencies returns the low and high group frequencies of a DTMF digit
+func Frequencies(digit byte) (low, high float64, ok bool) {
+	for r := range digits {
+		for c := range digits[r] {
+			if digits[r][c] == digit {
+				return rows[r], cols[c], true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// goertzelPower returns the Goertzel block power of a sine wave of amplitude a
+func goertzelPower(a float64) float64 {
+	p := a * blockSize / 2
+	return p * p
+}
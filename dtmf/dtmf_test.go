@@ -0,0 +1,121 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package dtmf
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// detect runs a new Detector over data in chunks of 160 bytes
+func detect(t *testing.T, data []byte, format int) []Event {
+	d, err := NewDetector(format, DefaultConfig)
+	if err != nil {
+		t.Fatalf("Failed to create Detector: %s\n", err)
+	}
+	var events []Event
+	for len(data) > 0 {
+		n := 160
+		if n > len(data) {
+			n = len(data)
+		}
+		events = append(events, d.Process(data[:n])...)
+		data = data[n:]
+	}
+	return append(events, d.Flush()...)
+}
+
+// dualTone returns ms milliseconds of two sine waves of amplitude a1 and a2
+// preceded by delay samples of silence
+func dualTone(f1, f2, a1, a2 float64, ms, delay int) []int16 {
+	s := make([]int16, delay+ms*8)
+	for i := range s[delay:] {
+		t := float64(i) / 8000
+		s[delay+i] = int16(a1*math.Sin(2*math.Pi*f1*t) + a2*math.Sin(2*math.Pi*f2*t))
+	}
+	return s
+}
+
+// Test the DTMF sample in all formats
+func TestDetect(t *testing.T) {
+	raw, err := os.ReadFile("../testing/dtmf-1234.raw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	for _, format := range []int{g711.Lpcm, g711.Alaw, g711.Ulaw} {
+		data := pcm.Encode(format, pcm.Decode(g711.Lpcm, raw, nil), nil)
+		events := detect(t, data, format)
+		var digits string
+		for _, e := range events {
+			digits += string(e.Digit)
+			if d := e.End - e.Start; d < 2000 || d > 3000 {
+				t.Errorf("Format %d: digit %c lasts %d samples", format, e.Digit, d)
+			}
+		}
+		if digits != "1234" {
+			t.Errorf("Format %d: expected: 1234, actual: %s", format, digits)
+		}
+	}
+}
+
+// Test that speech does not trigger the detector
+func TestSpeech(t *testing.T) {
+	for _, tc := range []struct {
+		file   string
+		format int
+	}{
+		{"../testing/speech.raw", g711.Lpcm},
+		{"../testing/speech.alaw", g711.Alaw},
+		{"../testing/speech.ulaw", g711.Ulaw},
+	} {
+		data, err := os.ReadFile(tc.file)
+		if err != nil {
+			t.Fatalf("Failed to read test data: %s\n", err)
+		}
+		if events := detect(t, data, tc.format); len(events) != 0 {
+			t.Errorf("%s: false detection: %v", tc.file, events)
+		}
+	}
+}
+
+// Test the Q.24 limits
+func TestLimits(t *testing.T) {
+	a := 4000.0
+	var tests = []struct {
+		name   string
+		tone   []int16
+		detect bool
+	}{
+		{"nominal", dualTone(770, 1336, a, a, 60, 0), true},
+		{"1.5% + 2Hz low", dualTone(697*0.985-2, 1633*0.985-2, a, a, 60, 0), true},
+		{"1.5% + 2Hz high", dualTone(941*1.015+2, 1209*1.015+2, a, a, 60, 0), true},
+		{"3.5% low", dualTone(852*0.965, 1477, a, a, 60, 0), false},
+		{"3.5% high", dualTone(852, 1477*1.035, a, a, 60, 0), false},
+		{"low twist 6dB", dualTone(852, 1477, a, a/2, 60, 0), true},
+		{"low twist 10dB", dualTone(852, 1477, a, a/3.16, 60, 0), false},
+		{"high twist 3dB", dualTone(852, 1477, a/1.41, a, 60, 0), true},
+		{"high twist 6dB", dualTone(852, 1477, a/2, a, 60, 0), false},
+		{"40ms", dualTone(941, 1336, a, a, 40, 0), true},
+		{"40ms unaligned", dualTone(941, 1336, a, a, 40, 60), true},
+		{"20ms", dualTone(941, 1336, a, a, 20, 0), false},
+		{"20ms unaligned", dualTone(941, 1336, a, a, 20, 60), false},
+		{"-30dBm0", dualTone(697, 1209, 720, 720, 60, 0), true},
+		{"-45dBm0", dualTone(697, 1209, 128, 128, 60, 0), false},
+	}
+	for _, tc := range tests {
+		events := detect(t, pcm.Encode(g711.Lpcm, tc.tone, nil), g711.Lpcm)
+		if (len(events) == 1) != tc.detect {
+			t.Errorf("%s: expected detection: %t, actual: %v", tc.name, tc.detect, events)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package echo implements a line echo canceller for G711 or LPCM audio in the
+manner of ITU-T G.168.
+
+An NLMS adaptive filter models the echo path from the far end reference to the
+near end signal, over a tail of up to 128ms, and subtracts the estimated echo.
+A Geigel double talk detector stops the adaptation while the near end talks, and
+a non-linear processor suppresses the residual echo, replacing it with comfort
+noise at the level of the near end background noise.
+*/
+package echo
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	// MaxTail is the longest echo path in ms
+	MaxTail   = 128
+	ms        = dsp.SampleRate / 1000
+	fullScale = 32768.0
+	delta     = 1e3   // NLMS regularisation against division by a silent reference
+	hangover  = 30    // Double talk hangover in ms
+	smoothing = 0.01  // Power smoothing coefficient per sample, about 12ms
+	noiseRise = 1e-4  // Comfort noise level rise coefficient per sample
+	farActive = -50.0 // Far end level above which echo is expected, in dBov
+	noiseSeed = 168
+)
+
+// Config holds the canceller parameters
+type Config struct {
+	Tail         int     // Echo path length in ms, up to MaxTail
+	Step         float64 // NLMS step size, between 0 and 2
+	DoubleTalk   float64 // Geigel threshold in dB, the least echo return loss expected
+	NLP          bool    // Suppress the residual echo
+	NLPThreshold float64 // Least attenuation of the far end in the residual to suppress it, in dB
+	ComfortNoise bool    // Replace the suppressed residual with comfort noise
+}
+
+// DefaultConfig is a 64ms canceller with the non-linear processor and comfort noise
+var DefaultConfig = Config{
+	Tail:         64,
+	Step:         0.5,
+	DoubleTalk:   6,
+	NLP:          true,
+	NLPThreshold: 18,
+	ComfortNoise: true,
+}
+
+// Canceller is a line echo canceller
+type Canceller struct {
+	format    int // data format
+	config    Config
+	taps      int       // filter length
+	w         []float64 // filter coefficients
+	x         []float64 // far end history, stored twice for contiguous access
+	pos       int       // position of the newest far end sample
+	xx        float64   // far end energy over the filter length
+	geigel    float64   // Geigel amplitude ratio
+	hold      int       // double talk hangover left in samples
+	farPower  float64   // smoothed far end power
+	nearPower float64   // smoothed near end power
+	resPower  float64   // smoothed residual power
+	noise     float64   // near end background noise power
+	rng       *rand.Rand
+	far, near []int16
+	out       []int16
+	dt        bool
+}
+
+// New returns a pointer to a Canceller for data in the given format
+func New(format int, c Config) (*Canceller, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid format")
+	}
+	if c.Tail <= 0 || c.Tail > MaxTail {
+		return nil, errors.New("invalid tail length")
+	}
+	if c.Step <= 0 || c.Step >= 2 {
+		return nil, errors.New("invalid step size")
+	}
+	taps := c.Tail * ms
+	return &Canceller{
+		format: format,
+		config: c,
+		taps:   taps,
+		w:      make([]float64, taps),
+		x:      make([]float64, 2*taps),
+		geigel: math.Pow(10, -c.DoubleTalk/20),
+		rng:    rand.New(rand.NewSource(noiseSeed)),
+	}, nil
+}
+
+// Process cancels the echo of a far end frame in the matching near end frame and
+// returns the result in the same format. Both frames must hold the same number of samples.
+func (c *Canceller) Process(far, near []byte) ([]byte, error) {
+	if len(far) != len(near) {
+		return nil, errors.New("frame length mismatch")
+	}
+	c.far = pcm.Decode(c.format, far, c.far[:0])
+	c.near = pcm.Decode(c.format, near, c.near[:0])
+	if cap(c.out) < len(c.near) {
+		c.out = make([]int16, len(c.near))
+	}
+	c.out = c.out[:len(c.near)]
+	c.ProcessSamples(c.far, c.near, c.out)
+	return pcm.Encode(c.format, c.out, nil), nil
+}
+
+// ProcessSamples cancels the echo of far end 16bit linear samples in the matching
+// near end samples and stores the result in out, which may be near.
+func (c *Canceller) ProcessSamples(far, near, out []int16) {
+	for i := range near {
+		out[i] = c.sample(float64(far[i]), float64(near[i]))
+	}
+}
+
+// DoubleTalk reports whether double talk was detected at the last sample
+func (c *Canceller) DoubleTalk() bool {
+	return c.dt
+}
+
+// sample processes one pair of samples
+func (c *Canceller) sample(far, near float64) int16 {
+	// Update the far end history and its energy
+	old := c.x[c.pos+c.taps-1]
+	if c.pos--; c.pos < 0 {
+		c.pos = c.taps - 1
+	}
+	c.x[c.pos], c.x[c.pos+c.taps] = far, far
+	c.xx += far*far - old*old
+	if c.xx < 0 {
+		c.xx = 0
+	}
+	x := c.x[c.pos : c.pos+c.taps]
+
+	// Estimate and subtract the echo
+	var y float64
+	for k, w := range c.w {
+		y += w * x[k]
+	}
+	e := near - y
+
+	// Geigel double talk detection against the largest recent far end sample
+	var peak float64
+	for _, v := range x {
+		if v = math.Abs(v); v > peak {
+			peak = v
+		}
+	}
+	if math.Abs(near) > c.geigel*peak && math.Abs(near) > 1 {
+		c.hold = hangover * ms
+	} else if c.hold > 0 {
+		c.hold--
+	}
+	c.dt = c.hold > 0
+
+	// Adapt while only the far end talks
+	if !c.dt && c.xx > 0 {
+		g := c.config.Step * e / (c.xx + delta)
+		for k := range c.w {
+			c.w[k] += g * x[k]
+		}
+	}
+
+	c.farPower += (far*far - c.farPower) * smoothing
+	c.nearPower += (near*near - c.nearPower) * smoothing
+	c.resPower += (e*e - c.resPower) * smoothing
+	active := c.farPower > fullScale*fullScale*dsp.DB(farActive)
+	if !c.dt {
+		// Track the background noise as the floor of the residual:
+		// follow falls at once and rises slowly
+		if c.noise == 0 || c.resPower < c.noise {
+			c.noise = c.resPower
+		} else {
+			c.noise += (c.resPower - c.noise) * noiseRise
+		}
+	}
+	if c.config.NLP && active && !c.dt && c.resPower*dsp.DB(c.config.NLPThreshold) < c.farPower {
+		e = 0
+		if c.config.ComfortNoise {
+			e = c.rng.NormFloat64() * math.Sqrt(c.noise)
+		}
+	}
+	return clip(e)
+}
+
+// ERLE returns the current echo return loss enhancement in dB, the attenuation of the
+// near end signal by the filter, before the non-linear processor
+func (c *Canceller) ERLE() float64 {
+	if c.resPower == 0 {
+		return 0
+	}
+	return 10 * math.Log10(c.nearPower/c.resPower)
+}
+
+// Reset discards the Canceller state. This permits reusing a Canceller rather than allocating a new one.
+func (c *Canceller) Reset() {
+	for i := range c.w {
+		c.w[i] = 0
+	}
+	for i := range c.x {
+		c.x[i] = 0
+	}
+	c.pos, c.xx, c.hold, c.dt = 0, 0, 0, false
+	c.farPower, c.nearPower, c.resPower, c.noise = 0, 0, 0, 0
+	c.rng.Seed(noiseSeed)
+}
+
+func clip(x float64) int16 {
+	x = math.Round(x)
+	if x > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(x)
+}
@@ -0,0 +1,157 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package echo
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// noise returns n samples of Gaussian noise with the given RMS
+func noise(rng *rand.Rand, rms float64, n int) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = rms * rng.NormFloat64()
+	}
+	return s
+}
+
+// echoPath returns a synthetic hybrid echo path: a pure delay followed by a
+// decaying random response, with an echo return loss of erl dB for white signals
+func echoPath(rng *rand.Rand, delay int, erl float64) []float64 {
+	h := make([]float64, delay+120)
+	var e float64
+	for i := delay; i < len(h); i++ {
+		h[i] = rng.NormFloat64() * math.Exp(-float64(i-delay)/25)
+		e += h[i] * h[i]
+	}
+	g := math.Pow(10, -erl/20) / math.Sqrt(e)
+	for i := range h {
+		h[i] *= g
+	}
+	return h
+}
+
+// convolve returns the echo of far through h plus extra
+func convolve(far, h, extra []float64) []int16 {
+	out := make([]int16, len(far))
+	for n := range far {
+		y := extra[n]
+		for k := 0; k < len(h) && k <= n; k++ {
+			y += h[k] * far[n-k]
+		}
+		out[n] = clip(y)
+	}
+	return out
+}
+
+// run passes far and near through a new Canceller in 20ms frames of the given format
+// and returns the output samples and the ERLE at the end of each frame
+func run(t *testing.T, c Config, format int, far, near []int16) ([]int16, []float64) {
+	ec, err := New(format, c)
+	if err != nil {
+		t.Fatalf("Failed to create Canceller: %s\n", err)
+	}
+	var out []int16
+	var erle []float64
+	for i := 0; i+160 <= len(far); i += 160 {
+		f := pcm.Encode(format, far[i:i+160], nil)
+		n := pcm.Encode(format, near[i:i+160], nil)
+		o, err := ec.Process(f, n)
+		if err != nil {
+			t.Fatalf("Process failed: %s\n", err)
+		}
+		out = pcm.Decode(format, o, out)
+		erle = append(erle, ec.ERLE())
+	}
+	return out, erle
+}
+
+func power(s []int16) float64 {
+	var p float64
+	for _, x := range s {
+		p += float64(x) * float64(x)
+	}
+	return 10 * math.Log10(p/float64(len(s))/(fullScale*fullScale))
+}
+
+// Test the convergence on a synthetic echo path in all formats
+func TestConvergence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	farF := noise(rng, 3000, 4*8000)
+	far := convolve(farF, []float64{1}, make([]float64, len(farF)))
+	near := convolve(farF, echoPath(rng, 160, 10), make([]float64, len(farF)))
+	c := DefaultConfig
+	c.NLP = false
+	for _, tc := range []struct {
+		format int
+		erle   float64
+	}{
+		{g711.Lpcm, 30},
+		{g711.Alaw, 20},
+		{g711.Ulaw, 20},
+	} {
+		_, erle := run(t, c, tc.format, far, near)
+		if e := erle[len(erle)-1]; e < tc.erle {
+			t.Errorf("Format %d: ERLE %.1f dB, expected at least %.1f dB", tc.format, e, tc.erle)
+		}
+	}
+}
+
+// Test that double talk does not disturb a converged filter and that
+// the non-linear processor removes the residual echo
+func TestDoubleTalk(t *testing.T) {
+	speech, err := os.ReadFile("../testing/speech.raw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	rng := rand.New(rand.NewSource(2))
+	n := 8 * 8000
+	farF := noise(rng, 3000, n)
+	// Near end speech between 3s and 5.5s over a quiet background
+	extra := noise(rng, 10, n)
+	for i, x := range pcm.Decode(g711.Lpcm, speech, nil) {
+		if 3*8000+i < n {
+			extra[3*8000+i] += 2 * float64(x)
+		}
+	}
+	far := convolve(farF, []float64{1}, make([]float64, n))
+	near := convolve(farF, echoPath(rng, 40, 10), extra)
+
+	c := DefaultConfig
+	c.NLP = false
+	out, erle := run(t, c, g711.Lpcm, far, near)
+	if e := erle[len(erle)-1]; e < 25 {
+		t.Errorf("ERLE after double talk %.1f dB, expected at least 25 dB", e)
+	}
+	// The near end speech passes through
+	if p, want := power(out[3*8000:5*8000]), power(convolve(extra[3*8000:5*8000], []float64{1}, make([]float64, 2*8000))); math.Abs(p-want) > 1 {
+		t.Errorf("Near end speech level %.1f dBov, expected %.1f dBov", p, want)
+	}
+
+	// The residual is replaced by comfort noise at the background level
+	background := power(convolve(extra[6*8000:], []float64{1}, make([]float64, 2*8000)))
+	out, _ = run(t, DefaultConfig, g711.Lpcm, far, near)
+	if p := power(out[6*8000:]); math.Abs(p-background) > 3 {
+		t.Errorf("Comfort noise %.1f dBov, expected %.1f dBov", p, background)
+	}
+	c.NLP, c.ComfortNoise = true, false
+	out, _ = run(t, c, g711.Lpcm, far, near)
+	if p := power(out[6*8000:]); p > background-10 {
+		t.Errorf("Residual echo with NLP %.1f dBov", p)
+	}
+	if _, err := New(g711.Alaw, Config{Tail: 200, Step: 0.5}); err == nil {
+		t.Error("Tail longer than 128ms accepted")
+	}
+}
@@ -0,0 +1,348 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package fax detects fax and modem tones in G711 or LPCM audio.
+
+It recognises the 1100Hz calling tone (CNG), the 2100Hz answer tone (CED/ANS)
+with and without phase reversals and amplitude modulation (ITU-T V.25 and V.8)
+and the HDLC flag preamble of V.21 channel 2 that starts T.30 fax negotiation.
+*/
+package fax
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// Tone is a detected fax or modem tone
+type Tone int
+
+const (
+	// Fax and modem tones
+	CNG         Tone = iota // Calling tone, 1100Hz 0.5s on 3s off
+	ANS                     // Answer tone, 2100Hz
+	ANSPR                   // Answer tone with phase reversals, /ANS
+	ANSam                   // Answer tone with 15Hz amplitude modulation
+	ANSamPR                 // Answer tone with amplitude modulation and phase reversals, /ANSam
+	V21Preamble             // V.21 channel 2 HDLC flags
+)
+
+const (
+	blockSize   = 80   // Samples per block, 10ms with both tones on an exact DFT bin
+	cngFreq     = 1100 // Calling tone frequency
+	ansFreq     = 2100 // Answer tone frequency
+	amFreq      = 15   // Answer tone modulation frequency
+	minLevel    = -43  // Lowest tone level in dBm0
+	toneEnergy  = 0.7  // Least fraction of the block energy in the tone frequency
+	cngMin      = 400  // Shortest CNG burst in ms
+	cngMax      = 700  // Longest CNG burst in ms
+	ansMin      = 500  // Shortest answer tone in ms
+	ansClassify = 1000 // Answer tone duration before it is classified in ms
+	maxGap      = 2    // Blocks a tone may drop out, such as at a phase reversal
+	amIndex     = 0.1  // Least modulation index of ANSam
+	markFreq    = 1650 // V.21 channel 2 mark frequency
+	spaceFreq   = 1850 // V.21 channel 2 space frequency
+	baud        = 300  // V.21 bit rate
+	carrier     = 0.5  // Least fraction of the energy in the V.21 carrier
+	flag        = 0x7E // HDLC flag
+	minFlags    = 3    // Consecutive flags of a preamble
+	ms          = dsp.SampleRate / 1000
+)
+
+var names = [...]string{"CNG", "ANS", "ANSPR", "ANSam", "ANSamPR", "V.21 preamble"}
+
+func (t Tone) String() string {
+	if t < 0 || int(t) >= len(names) {
+		return "unknown"
+	}
+	return names[t]
+}
+
+// Event is a detected tone
+type Event struct {
+	Tone  Tone  // Tone type
+	Start int64 // Sample offset of the start of the tone
+	End   int64 // Sample offset of the end of the tone or of its detection
+}
+
+// tone tracks the presence of a single frequency block by block
+type tone struct {
+	g      dsp.Goertzel
+	active bool         // the tone is present
+	start  int64        // start of the tone
+	end    int64        // end of the last block with the tone
+	gap    int          // blocks since the tone was last present
+	done   bool         // the tone was classified
+	dft    []complex128 // DFT of each block at the tone frequency, 2100Hz only
+}
+
+// Detector detects fax and modem tones in a stream of audio frames
+type Detector struct {
+	format   int     // input format
+	minPower float64 // Goertzel power at minLevel
+	framer   dsp.Framer
+	samples  []int16 // decoding buffer
+	offset   int64   // sample offset of the next block
+	cng      tone
+	ans      tone
+	fsk      *dsp.FSK
+	clock    *dsp.BitClock
+	minFSK   float64 // signal energy in the FSK window at minLevel
+	n        int64   // sample offset of the next sample
+	bits     uint8   // last 8 received bits
+	sinceFlg int     // bits since the last flag, -1 if none
+	flags    int     // consecutive flags
+	fskStart int64   // start of the V.21 carrier
+	fskOn    bool    // the V.21 carrier is present
+	fskDone  bool    // the preamble was reported
+	events   []Event
+}
+
+// NewDetector returns a pointer to a Detector for data in the given format
+func NewDetector(format int) (*Detector, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	a := dsp.Amplitude(minLevel)
+	window := dsp.SampleRate / baud
+	return &Detector{
+		format:   format,
+		minPower: math.Pow(a*blockSize/2, 2),
+		framer:   dsp.Framer{Size: blockSize},
+		cng:      tone{g: dsp.NewGoertzel(cngFreq)},
+		ans:      tone{g: dsp.NewGoertzel(ansFreq)},
+		fsk:      dsp.NewFSK(markFreq, spaceFreq, window),
+		clock:    dsp.NewBitClock(baud),
+		minFSK:   a * a / 2 * float64(window),
+		sinceFlg: -1,
+	}, nil
+}
+
+// Process detects tones in a frame of audio data and returns the
+// tones detected within it.
+func (d *Detector) Process(frame []byte) []Event {
+	d.samples = pcm.Decode(d.format, frame, d.samples[:0])
+	return d.ProcessSamples(d.samples)
+}
+
+// ProcessSamples detects tones in 16bit linear samples and returns the
+// tones detected within them.
+func (d *Detector) ProcessSamples(s []int16) []Event {
+	d.events = d.events[:0]
+	for _, x := range s {
+		d.demod(float64(x))
+	}
+	d.framer.Push(s, d.block)
+	return d.events
+}
+
+// Flush ends the stream and returns any tone that ended with it
+func (d *Detector) Flush() []Event {
+	d.events = d.events[:0]
+	d.endCNG()
+	d.endANS()
+	return d.events
+}
+
+// Reset discards the Detector state. This permits reusing a Detector rather than allocating a new one.
+func (d *Detector) Reset() {
+	d.framer.Reset()
+	d.fsk.Reset()
+	d.clock = dsp.NewBitClock(baud)
+	d.cng = tone{g: d.cng.g}
+	d.ans = tone{g: d.ans.g}
+	d.offset, d.n, d.bits, d.sinceFlg, d.flags = 0, 0, 0, -1, 0
+	d.fskOn, d.fskDone = false, false
+}
+
+// block tracks the tones in a single block
+func (d *Detector) block(s []float64) {
+	energy := dsp.Energy(s)
+	start := d.offset
+	d.offset += blockSize
+	// Calling tone
+	if d.present(d.cng.g.Power(s), energy) {
+		d.cng.on(start, d.offset)
+	} else if d.cng.off() {
+		d.endCNG()
+	}
+	// Answer tone
+	if p := d.ans.g.Power(s); d.present(p, energy) {
+		d.ans.on(start, d.offset)
+		d.ans.dft = append(d.ans.dft, dft(s))
+		if !d.ans.done && d.ans.end-d.ans.start >= ansClassify*ms {
+			d.classifyANS()
+		}
+	} else if d.ans.active {
+		d.ans.dft = append(d.ans.dft, dft(s))
+		if d.ans.off() {
+			d.endANS()
+		}
+	}
+}
+
+// present reports whether a block holds a tone of Goertzel power p
+func (d *Detector) present(p, energy float64) bool {
+	return p >= d.minPower && dsp.ToneEnergy(p, blockSize) >= toneEnergy*energy
+}
+
+// on marks the tone present in a block
+func (t *tone) on(start, end int64) {
+	if !t.active {
+		t.active, t.start, t.done, t.dft = true, start, false, t.dft[:0]
+	}
+	t.end, t.gap = end, 0
+}
+
+// off marks the tone missing in a block and reports whether it has ended
+func (t *tone) off() bool {
+	if !t.active {
+		return false
+	}
+	t.gap++
+	return t.gap > maxGap
+}
+
+// endCNG ends the calling tone and reports it if its duration fits
+func (d *Detector) endCNG() {
+	if !d.cng.active {
+		return
+	}
+	if l := d.cng.end - d.cng.start; l >= cngMin*ms && l <= cngMax*ms {
+		d.events = append(d.events, Event{Tone: CNG, Start: d.cng.start, End: d.cng.end})
+	}
+	d.cng.active = false
+}
+
+// endANS ends the answer tone and classifies it if it was not classified yet
+func (d *Detector) endANS() {
+	if !d.ans.active {
+		return
+	}
+	if !d.ans.done && d.ans.end-d.ans.start >= ansMin*ms {
+		d.ans.dft = d.ans.dft[:(d.ans.end-d.ans.start)/blockSize]
+		d.classifyANS()
+	}
+	d.ans.active = false
+}
+
+// classifyANS looks for phase reversals and amplitude modulation in the answer tone
+func (d *Detector) classifyANS() {
+	d.ans.done = true
+	blocks := d.ans.dft
+	// The frequency offset of the tone rotates the phase by a constant step per block
+	var steps []float64
+	for i := 1; i < len(blocks); i++ {
+		steps = append(steps, cmplx.Phase(blocks[i]*cmplx.Conj(blocks[i-1])))
+	}
+	sort.Float64s(steps)
+	drift := steps[len(steps)/2]
+	// A reversal turns the phase by π, the block in the middle may hold part of both phases
+	reversal := make([]bool, len(blocks))
+	reversals := 0
+	for i := 1; i+1 < len(blocks); i++ {
+		turn := cmplx.Phase(blocks[i+1] * cmplx.Conj(blocks[i-1]) * cmplx.Rect(1, -2*drift))
+		if math.Abs(turn) > math.Pi/2 && !reversal[i-1] {
+			reversal[i], reversals = true, reversals+1
+		}
+	}
+	// Modulation index of the envelope at 15Hz, leaving out the blocks around reversals
+	var mean float64
+	var used int
+	for i, b := range blocks {
+		if !near(reversal, i) {
+			mean += cmplx.Abs(b)
+			used++
+		}
+	}
+	am := false
+	if used > 0 {
+		mean /= float64(used)
+		var c complex128
+		for i, b := range blocks {
+			if !near(reversal, i) {
+				c += complex(cmplx.Abs(b)-mean, 0) * cmplx.Rect(1, -2*math.Pi*amFreq*float64(i*blockSize)/dsp.SampleRate)
+			}
+		}
+		am = 2*cmplx.Abs(c)/(float64(used)*mean) >= amIndex
+	}
+	t := ANS
+	switch {
+	case am && reversals > 0:
+		t = ANSamPR
+	case am:
+		t = ANSam
+	case reversals > 0:
+		t = ANSPR
+	}
+	d.events = append(d.events, Event{Tone: t, Start: d.ans.start, End: d.ans.end})
+}
+
+// near reports whether block i is next to a phase reversal
+func near(reversal []bool, i int) bool {
+	for j := i - 1; j <= i+1; j++ {
+		if j >= 0 && j < len(reversal) && reversal[j] {
+			return true
+		}
+	}
+	return false
+}
+
+// dft returns the DFT of a block at the answer tone frequency
+func dft(s []float64) complex128 {
+	var c complex128
+	for i, x := range s {
+		c += complex(x, 0) * cmplx.Rect(1, -2*math.Pi*ansFreq*float64(i)/dsp.SampleRate)
+	}
+	return c
+}
+
+// demod demodulates V.21 channel 2 and looks for the HDLC flag preamble
+func (d *Detector) demod(x float64) {
+	v := d.fsk.Demod(x)
+	d.n++
+	if d.fsk.Energy < d.minFSK || d.fsk.Carrier() < carrier {
+		d.fskOn, d.fskDone, d.flags, d.sinceFlg = false, false, 0, -1
+		return
+	}
+	if !d.fskOn {
+		d.fskOn, d.fskStart = true, d.n
+	}
+	if !d.clock.Sample(v > 0) {
+		return
+	}
+	d.bits <<= 1
+	if v > 0 {
+		d.bits |= 1
+	}
+	if d.sinceFlg >= 0 {
+		d.sinceFlg++
+	}
+	if d.bits != flag {
+		if d.sinceFlg > 8 {
+			d.flags, d.sinceFlg = 0, -1
+		}
+		return
+	}
+	if d.sinceFlg == 8 {
+		d.flags++
+	} else {
+		d.flags = 1
+	}
+	d.sinceFlg = 0
+	if d.flags >= minFlags && !d.fskDone {
+		d.fskDone = true
+		d.events = append(d.events, Event{Tone: V21Preamble, Start: d.fskStart, End: d.n})
+	}
+}
@@ -0,0 +1,135 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package fax
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// answerTone returns ms milliseconds of a 2100Hz tone of amplitude a with optional
+// phase reversals every 450ms and 15Hz amplitude modulation of index m
+func answerTone(a float64, ms int, reversals bool, m float64) []int16 {
+	s := make([]int16, ms*8)
+	phase := 0.0
+	for i := range s {
+		if reversals && i > 0 && i%3600 == 0 {
+			phase += math.Pi
+		}
+		t := float64(i) / 8000
+		s[i] = int16(a * (1 + m*math.Sin(2*math.Pi*15*t)) * math.Sin(2*math.Pi*2100*t+phase))
+	}
+	return s
+}
+
+// sine returns ms milliseconds of a sine wave of amplitude a
+func sine(f, a float64, ms int) []int16 {
+	s := make([]int16, ms*8)
+	for i := range s {
+		s[i] = int16(a * math.Sin(2*math.Pi*f*float64(i)/8000))
+	}
+	return s
+}
+
+// v21 returns V.21 channel 2 FSK of amplitude a carrying n HDLC flags
+func v21(a float64, n int) []int16 {
+	var s []int16
+	phase := 0.0
+	for i := 0; i < n*8; i++ {
+		f := 1850.0
+		if (0x7E>>(7-i%8))&1 == 1 {
+			f = 1650
+		}
+		// 300 baud bits at 8000Hz alternate between 26 and 27 samples
+		for j := 0; j < (i+1)*80/3-i*80/3; j++ {
+			phase += 2 * math.Pi * f / 8000
+			s = append(s, int16(a*math.Sin(phase)))
+		}
+	}
+	return s
+}
+
+// detect runs a new Detector over samples encoded to the given format
+func detect(t *testing.T, s []int16, format int) []Event {
+	d, err := NewDetector(format)
+	if err != nil {
+		t.Fatalf("Failed to create Detector: %s\n", err)
+	}
+	data := pcm.Encode(format, s, nil)
+	var events []Event
+	for len(data) > 0 {
+		n := 160
+		if n > len(data) {
+			n = len(data)
+		}
+		events = append(events, d.Process(data[:n])...)
+		data = data[n:]
+	}
+	return append(events, d.Flush()...)
+}
+
+// Test every tone in every format
+func TestDetect(t *testing.T) {
+	silence := make([]int16, 2000)
+	var tests = []struct {
+		signal []int16
+		tone   Tone
+	}{
+		{sine(1100, 3000, 500), CNG},
+		{answerTone(3000, 3300, false, 0), ANS},
+		{answerTone(3000, 3300, true, 0), ANSPR},
+		{answerTone(3000, 3300, false, 0.2), ANSam},
+		{answerTone(3000, 3300, true, 0.2), ANSamPR},
+		{answerTone(300, 600, false, 0), ANS},
+		{v21(3000, 40), V21Preamble},
+		{v21(300, 40), V21Preamble},
+	}
+	for _, format := range []int{g711.Lpcm, g711.Alaw, g711.Ulaw} {
+		for _, tc := range tests {
+			s := append(append(append([]int16{}, silence...), tc.signal...), silence...)
+			events := detect(t, s, format)
+			if len(events) != 1 || events[0].Tone != tc.tone {
+				t.Errorf("Format %d: expected: %s, actual: %v", format, tc.tone, events)
+				continue
+			}
+			if e := events[0]; e.Start < 1800 || e.Start > 2400 || e.End > int64(len(s)) {
+				t.Errorf("Format %d: %s: unexpected timestamps: %v", format, tc.tone, e)
+			}
+		}
+	}
+}
+
+// Test tones that must not be detected
+func TestReject(t *testing.T) {
+	var tests = []struct {
+		name   string
+		signal []int16
+	}{
+		{"continuous 1100Hz", sine(1100, 3000, 2000)},
+		{"short 2100Hz", sine(2100, 3000, 300)},
+		{"2000Hz", sine(2000, 3000, 3000)},
+		{"V.21 marks", sine(1650, 3000, 1000)},
+	}
+	for _, tc := range tests {
+		if events := detect(t, tc.signal, g711.Alaw); len(events) != 0 {
+			t.Errorf("%s: false detection: %v", tc.name, events)
+		}
+	}
+	data, err := os.ReadFile("../testing/speech.alaw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	if events := detect(t, pcm.Decode(g711.Alaw, data, nil), g711.Alaw); len(events) != 0 {
+		t.Errorf("speech: false detection: %v", events)
+	}
+}
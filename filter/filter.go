@@ -0,0 +1,256 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package filter implements cascaded biquad IIR filters for G711 or LPCM audio
+at 8000Hz, with the usual telephony conditioning: a 300 - 3400Hz band-pass,
+a DC blocker and notches for 50 or 60Hz mains hum and its harmonics.
+
+The biquad sections follow the Audio EQ Cookbook designs of Robert
+Bristow-Johnson. A Filter runs a cascade of sections on 16bit linear or
+floating point samples, on frames in place, or wraps an io.Reader or
+io.Writer, such as the input or output of a g711 Encoder or Decoder.
+*/
+package filter
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	butterworth = math.Sqrt2 / 2 // Q of a second order Butterworth section
+	dcCutoff    = 20             // DC blocker cutoff frequency in Hz
+	notchWidth  = 4              // Hum notch bandwidth in Hz
+)
+
+// Q of the two sections of a fourth order Butterworth filter
+var butterworth4 = [2]float64{0.54119610, 1.30656296}
+
+// Biquad is a second order IIR filter section
+type Biquad struct {
+	B0, B1, B2 float64 // Feed forward coefficients
+	A1, A2     float64 // Feedback coefficients, normalised to A0 = 1
+	z1, z2     float64 // transposed direct form II state
+}
+
+// normalise returns a Biquad from cookbook coefficients
+func normalise(b0, b1, b2, a0, a1, a2 float64) Biquad {
+	return Biquad{B0: b0 / a0, B1: b1 / a0, B2: b2 / a0, A1: a1 / a0, A2: a2 / a0}
+}
+
+// omega returns the cosine and the alpha term of a cookbook design
+func omega(freq, q float64) (float64, float64) {
+	w := 2 * math.Pi * freq / dsp.SampleRate
+	return math.Cos(w), math.Sin(w) / (2 * q)
+}
+
+// LowPass returns a second order low-pass section with cutoff freq in Hz and quality factor q
+func LowPass(freq, q float64) Biquad {
+	c, alpha := omega(freq, q)
+	return normalise((1-c)/2, 1-c, (1-c)/2, 1+alpha, -2*c, 1-alpha)
+}
+
+// HighPass returns a second order high-pass section with cutoff freq in Hz and quality factor q
+func HighPass(freq, q float64) Biquad {
+	c, alpha := omega(freq, q)
+	return normalise((1+c)/2, -(1 + c), (1+c)/2, 1+alpha, -2*c, 1-alpha)
+}
+
+// BandPass returns a band-pass section of 0dB peak gain at freq Hz and quality factor q
+func BandPass(freq, q float64) Biquad {
+	c, alpha := omega(freq, q)
+	return normalise(alpha, 0, -alpha, 1+alpha, -2*c, 1-alpha)
+}
+
+// Notch returns a band-stop section centred at freq Hz with quality factor q
+func Notch(freq, q float64) Biquad {
+	c, alpha := omega(freq, q)
+	return normalise(1, -2*c, 1, 1+alpha, -2*c, 1-alpha)
+}
+
+// Filter processes a single sample
+func (b *Biquad) Filter(x float64) float64 {
+	y := b.B0*x + b.z1
+	b.z1 = b.B1*x - b.A1*y + b.z2
+	b.z2 = b.B2*x - b.A2*y
+	return y
+}
+
+// Reset clears the section state
+func (b *Biquad) Reset() {
+	b.z1, b.z2 = 0, 0
+}
+
+// stable reports whether the poles of the section lie inside the unit circle
+func (b *Biquad) stable() bool {
+	for _, c := range []float64{b.B0, b.B1, b.B2, b.A1, b.A2} {
+		if math.IsNaN(c) || math.IsInf(c, 0) {
+			return false
+		}
+	}
+	return math.Abs(b.A2) < 1 && math.Abs(b.A1) < 1+b.A2
+}
+
+// TelephoneBand returns the sections of a 300 - 3400Hz band-pass filter,
+// fourth order Butterworth at each edge
+func TelephoneBand() []Biquad {
+	var s []Biquad
+	for _, q := range butterworth4 {
+		s = append(s, HighPass(300, q))
+	}
+	for _, q := range butterworth4 {
+		s = append(s, LowPass(3400, q))
+	}
+	return s
+}
+
+// DCBlocker returns the section of a high-pass filter that removes DC offset
+func DCBlocker() []Biquad {
+	return []Biquad{HighPass(dcCutoff, butterworth)}
+}
+
+// HumNotch returns the sections of a filter that removes mains hum at mains Hz,
+// usually 50 or 60, and its harmonics up to the given count, including the fundamental
+func HumNotch(mains float64, harmonics int) []Biquad {
+	var s []Biquad
+	for k := 1; k <= harmonics && float64(k)*mains < dsp.SampleRate/2; k++ {
+		f := float64(k) * mains
+		s = append(s, Notch(f, f/notchWidth))
+	}
+	return s
+}
+
+// Filter is a cascade of biquad sections
+type Filter struct {
+	format  int // data format
+	stages  []Biquad
+	samples []int16
+}
+
+// New returns a pointer to a Filter of data in the given format that runs the
+// sections in order. The sections are copied, each Filter keeps its own state.
+func New(format int, stages ...Biquad) (*Filter, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid format")
+	}
+	if len(stages) == 0 {
+		return nil, errors.New("no filter sections")
+	}
+	f := &Filter{format: format, stages: make([]Biquad, len(stages))}
+	for i, b := range stages {
+		b.Reset()
+		if !b.stable() {
+			return nil, errors.New("unstable filter section")
+		}
+		f.stages[i] = b
+	}
+	return f, nil
+}
+
+// Process filters a frame of audio data in place. LPCM frames must hold whole samples.
+func (f *Filter) Process(frame []byte) error {
+	if f.format == g711.Lpcm && len(frame)%2 != 0 {
+		return errors.New("odd LPCM frame length")
+	}
+	f.samples = pcm.Decode(f.format, frame, f.samples[:0])
+	f.ProcessSamples(f.samples)
+	pcm.Encode(f.format, f.samples, frame[:0])
+	return nil
+}
+
+// ProcessSamples filters 16bit linear samples in place
+func (f *Filter) ProcessSamples(s []int16) {
+	for i, x := range s {
+		s[i] = clip(f.filter(float64(x)))
+	}
+}
+
+// ProcessFloat filters floating point samples of any scale in place
+func (f *Filter) ProcessFloat(s []float64) {
+	for i, x := range s {
+		s[i] = f.filter(x)
+	}
+}
+
+// filter runs a sample through the cascade
+func (f *Filter) filter(x float64) float64 {
+	for i := range f.stages {
+		x = f.stages[i].Filter(x)
+	}
+	return x
+}
+
+// Reset discards the Filter state. This permits reusing a Filter rather than allocating a new one.
+func (f *Filter) Reset() {
+	for i := range f.stages {
+		f.stages[i].Reset()
+	}
+}
+
+func clip(x float64) int16 {
+	x = math.Round(x)
+	if x > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(x)
+}
+
+// Reader filters the data read from an io.Reader
+type Reader struct {
+	source *pcm.ProcessReader
+}
+
+// NewReader returns a pointer to a Reader that filters data read from r,
+// in the format of the Filter
+func NewReader(r io.Reader, f *Filter) (*Reader, error) {
+	if r == nil {
+		return nil, errors.New("io.Reader is nil")
+	}
+	if f == nil {
+		return nil, errors.New("filter is nil")
+	}
+	return &Reader{pcm.NewProcessReader(r, f.format, f.Process)}, nil
+}
+
+// Read reads data from the source and filters it. It returns
+// the number of bytes read and any error encountered.
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.source.Read(p)
+}
+
+// Writer filters the data written to an io.Writer
+type Writer struct {
+	dest *pcm.ProcessWriter
+}
+
+// NewWriter returns a pointer to a Writer that filters data written to w,
+// in the format of the Filter
+func NewWriter(w io.Writer, f *Filter) (*Writer, error) {
+	if w == nil {
+		return nil, errors.New("io.Writer is nil")
+	}
+	if f == nil {
+		return nil, errors.New("filter is nil")
+	}
+	return &Writer{pcm.NewProcessWriter(w, f.format, f.Process)}, nil
+}
+
+// Write filters the contents of p and writes them to the destination.
+// It returns the number of bytes written and any error encountered.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.dest.Write(p)
+}
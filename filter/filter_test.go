@@ -0,0 +1,164 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// sine returns ms milliseconds of a sine wave of the given frequency and amplitude
+func sine(freq, amplitude float64, ms int) []int16 {
+	s := make([]int16, ms*8)
+	for i := range s {
+		s[i] = int16(math.Round(amplitude * math.Sin(2*math.Pi*freq*float64(i)/8000)))
+	}
+	return s
+}
+
+// gain returns the level change in dB of a sine wave through the filter sections,
+// measured after the filter settles
+func gain(t *testing.T, freq float64, stages []Biquad) float64 {
+	f, err := New(g711.Lpcm, stages...)
+	if err != nil {
+		t.Fatalf("Failed to create Filter: %s\n", err)
+	}
+	in := sine(freq, 10000, 2000)
+	out := append([]int16{}, in...)
+	f.ProcessSamples(out)
+	var pin, pout float64
+	for i := 8000; i < len(in); i++ {
+		pin += float64(in[i]) * float64(in[i])
+		pout += float64(out[i]) * float64(out[i])
+	}
+	return 10 * math.Log10(pout/pin)
+}
+
+// Test the frequency response of the telephony filters
+func TestResponse(t *testing.T) {
+	var tests = []struct {
+		name   string
+		stages []Biquad
+		freq   float64
+		min    float64
+		max    float64
+	}{
+		{"band 1kHz", TelephoneBand(), 1000, -0.5, 0.5},
+		{"band 100Hz", TelephoneBand(), 100, math.Inf(-1), -30},
+		{"band 300Hz", TelephoneBand(), 300, -3.5, -2.5},
+		{"band 3400Hz", TelephoneBand(), 3400, -3.5, -2.5},
+		{"band 3900Hz", TelephoneBand(), 3900, math.Inf(-1), -20},
+		{"dc 300Hz", DCBlocker(), 300, -0.1, 0.1},
+		{"hum 50Hz", HumNotch(50, 3), 50, math.Inf(-1), -30},
+		{"hum 150Hz", HumNotch(50, 3), 150, math.Inf(-1), -30},
+		{"hum 60Hz", HumNotch(60, 5), 60, math.Inf(-1), -30},
+		{"hum 300Hz", HumNotch(60, 5), 300, math.Inf(-1), -30},
+		{"hum 1kHz", HumNotch(50, 3), 1000, -0.1, 0.1},
+	}
+	for _, tc := range tests {
+		if g := gain(t, tc.freq, tc.stages); g < tc.min || g > tc.max {
+			t.Errorf("%s: gain %.2f dB, expected %.1f to %.1f", tc.name, g, tc.min, tc.max)
+		}
+	}
+	if n := len(HumNotch(60, 100)); n != 66 {
+		t.Errorf("HumNotch returned %d sections above Nyquist, expected 66", n)
+	}
+	if _, err := New(g711.Lpcm, LowPass(5000, butterworth)); err == nil {
+		t.Error("Unstable section accepted")
+	}
+	if _, err := New(g711.Lpcm); err == nil {
+		t.Error("Empty cascade accepted")
+	}
+}
+
+// Test DC removal on integer and floating point samples
+func TestDCBlocker(t *testing.T) {
+	s := make([]float64, 8000)
+	for i := range s {
+		s[i] = 0.5 + 0.1*math.Sin(2*math.Pi*1000*float64(i)/8000)
+	}
+	f, _ := New(g711.Lpcm, DCBlocker()...)
+	f.ProcessFloat(s)
+	var mean float64
+	for _, x := range s[4000:] {
+		mean += x / 4000
+	}
+	if math.Abs(mean) > 1e-3 {
+		t.Errorf("Float DC offset %.4f after filtering", mean)
+	}
+	for _, format := range []int{g711.Alaw, g711.Ulaw, g711.Lpcm} {
+		in := sine(1000, 1000, 1000)
+		for i := range in {
+			in[i] += 3000
+		}
+		data := pcm.Encode(format, in, nil)
+		f, _ := New(format, DCBlocker()...)
+		if err := f.Process(data); err != nil {
+			t.Fatalf("Process failed: %s\n", err)
+		}
+		mean = 0
+		out := pcm.Decode(format, data, nil)
+		for _, x := range out[4000:] {
+			mean += float64(x) / 4000
+		}
+		if math.Abs(mean) > 30 {
+			t.Errorf("Format %d: DC offset %.1f after filtering", format, mean)
+		}
+	}
+}
+
+// Test that the Reader and Writer match Process over odd LPCM chunks
+func TestReaderWriter(t *testing.T) {
+	stages := append(TelephoneBand(), HumNotch(50, 5)...)
+	data := pcm.Encode(g711.Lpcm, sine(440, 8000, 1000), nil)
+	want := append([]byte{}, data...)
+	f, _ := New(g711.Lpcm, stages...)
+	f.Process(want)
+
+	f.Reset()
+	var out bytes.Buffer
+	w, err := NewWriter(&out, f)
+	if err != nil {
+		t.Fatalf("Failed to create Writer: %s\n", err)
+	}
+	for chunk := data; len(chunk) > 0; {
+		n := 333
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+		w.Write(chunk[:n])
+		chunk = chunk[n:]
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Error("Writer output differs from Process")
+	}
+
+	f.Reset()
+	r, err := NewReader(bytes.NewReader(data), f)
+	if err != nil {
+		t.Fatalf("Failed to create Reader: %s\n", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Reader output differs from Process: %v", err)
+	}
+
+	f.Reset()
+	r, _ = NewReader(iotest.OneByteReader(bytes.NewReader(data)), f)
+	got, err = io.ReadAll(iotest.OneByteReader(r))
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("One byte Reader output differs from Process: %v", err)
+	}
+}
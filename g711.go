@@ -17,6 +17,8 @@ package g711
 import (
 	"errors"
 	"io"
+
+	"github.com/zaf/g711/resample"
 )
 
 const (
@@ -28,19 +30,42 @@ const (
 
 // Decoder reads G711 PCM data and decodes it to 16bit 8000Hz LPCM
 type Decoder struct {
-	decode func([]byte) []byte // decoding function
-	source io.Reader           // source data
+	input    int                 // input format
+	decode   func([]byte) []byte // decoding function
+	gain     *GainTable          // gain applied before decoding
+	source   io.Reader           // source data
+	rate     int                 // output sample rate, 0 for 8000Hz
+	output   *resample.Reader    // resampled output
+	channels int                 // interleaved channels
 }
 
 // Encoder encodes 16bit 8000Hz LPCM data to G711 PCM or
 // directly transcodes between A-law and u-law
 type Encoder struct {
 	input       int                 // input format
+	output      int                 // output format
 	encode      func([]byte) []byte // encoding function
 	transcode   func([]byte) []byte // transcoding function
+	gain        *GainTable          // gain applied after encoding
 	destination io.Writer           // output data
+	rate        int                 // input sample rate, 0 for 8000Hz
+	input8k     *resample.Writer    // resampled input
+	channels    int                 // interleaved input channels
+	channel     int                 // encoded channel or Downmix
+	partial     []byte              // bytes of an incomplete input frame
+	mono        []byte              // single channel input
 }
 
+// decoderSource is the 8000Hz LPCM output of a Decoder before resampling
+type decoderSource struct{ r *Decoder }
+
+func (d decoderSource) Read(p []byte) (int, error) { return d.r.read(p) }
+
+// encoderSink is the 8000Hz LPCM input of an Encoder after resampling
+type encoderSink struct{ w *Encoder }
+
+func (e encoderSink) Write(p []byte) (int, error) { return e.w.write(p) }
+
 // NewAlawDecoder returns a pointer to a Decoder that implements an io.Reader.
 // It takes as input the source data Reader.
 func NewAlawDecoder(reader io.Reader) (*Decoder, error) {
@@ -48,6 +73,7 @@ func NewAlawDecoder(reader io.Reader) (*Decoder, error) {
 		return nil, errors.New("io.Reader is nil")
 	}
 	r := Decoder{
+		input:  Alaw,
 		decode: DecodeAlaw,
 		source: reader,
 	}
@@ -61,6 +87,7 @@ func NewUlawDecoder(reader io.Reader) (*Decoder, error) {
 		return nil, errors.New("io.Reader is nil")
 	}
 	r := Decoder{
+		input:  Ulaw,
 		decode: DecodeUlaw,
 		source: reader,
 	}
@@ -78,6 +105,7 @@ func NewAlawEncoder(writer io.Writer, input int) (*Encoder, error) {
 	}
 	w := Encoder{
 		input:       input,
+		output:      Alaw,
 		encode:      EncodeAlaw,
 		transcode:   Ulaw2Alaw,
 		destination: writer,
@@ -96,6 +124,7 @@ func NewUlawEncoder(writer io.Writer, input int) (*Encoder, error) {
 	}
 	w := Encoder{
 		input:       input,
+		output:      Ulaw,
 		encode:      EncodeUlaw,
 		transcode:   Alaw2Ulaw,
 		destination: writer,
@@ -109,7 +138,7 @@ func (r *Decoder) Reset(reader io.Reader) error {
 		return errors.New("io.Reader is nil")
 	}
 	r.source = reader
-	return nil
+	return r.SetOutputRate(r.rate)
 }
 
 // Reset discards the Encoder state. This permits reusing an Encoder rather than allocating a new one.
@@ -118,17 +147,115 @@ func (w *Encoder) Reset(writer io.Writer) error {
 		return errors.New("io.Writer is nil")
 	}
 	w.destination = writer
+	w.partial = w.partial[:0]
+	if w.rate == 0 {
+		return nil
+	}
+	return w.SetInputRate(w.rate)
+}
+
+// SetGain sets a gain in dB that the Decoder applies to the G711 data before decoding it.
+// A gain of 0 dB disables gain adjustment.
+func (r *Decoder) SetGain(dB float64) error {
+	if dB == 0 {
+		r.gain = nil
+		return nil
+	}
+	t, err := Gain(r.input, dB)
+	if err != nil {
+		return err
+	}
+	r.gain = t
+	return nil
+}
+
+// SetGain sets a gain in dB that the Encoder applies to the encoded or transcoded G711 data.
+// A gain of 0 dB disables gain adjustment.
+func (w *Encoder) SetGain(dB float64) error {
+	if dB == 0 {
+		w.gain = nil
+		return nil
+	}
+	t, err := Gain(w.output, dB)
+	if err != nil {
+		return err
+	}
+	w.gain = t
+	return nil
+}
+
+// SetOutputRate sets the sample rate in Hz of the LPCM data the Decoder produces.
+// The decoded data is resampled from 8000Hz.
+func (r *Decoder) SetOutputRate(rate int) error {
+	if rate == 0 || rate == 8000 {
+		r.rate, r.output = 0, nil
+		return nil
+	}
+	rs, err := resample.New(8000, rate)
+	if err != nil {
+		return err
+	}
+	if r.channels > 1 {
+		rs.SetChannels(r.channels)
+	}
+	r.output, err = resample.NewReader(decoderSource{r}, rs)
+	if err != nil {
+		return err
+	}
+	r.rate = rate
 	return nil
 }
 
+// SetInputRate sets the sample rate in Hz of the LPCM data the Encoder takes.
+// The data is resampled to 8000Hz before encoding and Flush must be called at
+// the end of the stream.
+func (w *Encoder) SetInputRate(rate int) error {
+	if w.input != Lpcm {
+		return errors.New("input rate requires LPCM input")
+	}
+	if rate == 0 || rate == 8000 {
+		w.rate, w.input8k = 0, nil
+		return nil
+	}
+	rs, err := resample.New(rate, 8000)
+	if err != nil {
+		return err
+	}
+	w.input8k, err = resample.NewWriter(encoderSink{w}, rs)
+	if err != nil {
+		return err
+	}
+	w.rate = rate
+	return nil
+}
+
+// Flush encodes and writes the data held for resampling. It is a no-op without an input rate.
+func (w *Encoder) Flush() error {
+	if w.input8k == nil {
+		return nil
+	}
+	return w.input8k.Close()
+}
+
 // Read decodes G711 data. Reads up to len(p) bytes into p, returns the number
 // of bytes read and any error encountered.
-func (r *Decoder) Read(p []byte) (i int, err error) {
+func (r *Decoder) Read(p []byte) (int, error) {
+	if r.output != nil {
+		return r.output.Read(p)
+	}
+	return r.read(p)
+}
+
+// read decodes G711 data to 8000Hz LPCM
+func (r *Decoder) read(p []byte) (i int, err error) {
 	if len(p) == 0 {
 		return
 	}
 	b := make([]byte, len(p)/2)
 	i, err = r.source.Read(b)
+	if r.gain != nil {
+		r.gain.ApplyGain(b[:i])
+	}
 	copy(p, r.decode(b))
 	i *= 2 // Report back the correct number of bytes
 	return
@@ -137,18 +264,45 @@ func (r *Decoder) Read(p []byte) (i int, err error) {
 // Write encodes G711 Data. Writes len(p) bytes from p to the underlying data stream,
 // returns the number of bytes written from p (0 <= n <= len(p)) and any error encountered
 // that caused the write to stop early.
-func (w *Encoder) Write(p []byte) (i int, err error) {
+func (w *Encoder) Write(p []byte) (int, error) {
+	if w.channels > 1 {
+		if _, err := w.writeMono(w.toMono(p)); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return w.writeMono(p)
+}
+
+// writeMono resamples if needed and encodes single channel data
+func (w *Encoder) writeMono(p []byte) (int, error) {
+	if w.input8k != nil {
+		return w.input8k.Write(p)
+	}
+	return w.write(p)
+}
+
+// write encodes 8000Hz LPCM or transcodes G711 data
+func (w *Encoder) write(p []byte) (i int, err error) {
 	if len(p) == 0 {
 		return
 	}
 	if w.input == Lpcm { // Encode LPCM data to G711
-		i, err = w.destination.Write(w.encode(p))
+		i, err = w.destination.Write(w.applyGain(w.encode(p)))
 		if err == nil && len(p)%2 != 0 {
 			err = errors.New("odd number of LPCM bytes, incomplete frame")
 		}
 		i *= 2 // Report back the correct number of bytes written from p
 	} else { // Trans-code
-		i, err = w.destination.Write(w.transcode(p))
+		i, err = w.destination.Write(w.applyGain(w.transcode(p)))
 	}
 	return
 }
+
+// applyGain applies the Encoder gain to G711 data in place
+func (w *Encoder) applyGain(data []byte) []byte {
+	if w.gain != nil {
+		w.gain.ApplyGain(data)
+	}
+	return data
+}
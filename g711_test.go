@@ -14,6 +14,7 @@ package g711
 import (
 	"bytes"
 	"io"
+	"math"
 	"os"
 	"testing"
 	"testing/iotest"
@@ -235,3 +236,168 @@ func BenchmarkADecode(b *testing.B) {
 		}
 	}
 }
+
+// Test encoding from and decoding to 16kHz LPCM
+func TestSampleRate(t *testing.T) {
+	const n = 16000
+	in := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		x := int16(math.Round(8000 * math.Sin(2*math.Pi*1000*float64(i)/16000)))
+		in[2*i], in[2*i+1] = byte(x), byte(x>>8)
+	}
+	var encoded bytes.Buffer
+	enc, _ := NewAlawEncoder(&encoded, Lpcm)
+	if err := enc.SetInputRate(16000); err != nil {
+		t.Fatalf("SetInputRate failed: %s\n", err)
+	}
+	for i := 0; i < len(in); i += 321 {
+		end := i + 321
+		if end > len(in) {
+			end = len(in)
+		}
+		if _, err := enc.Write(in[i:end]); err != nil {
+			t.Fatalf("Write failed: %s\n", err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("Flush failed: %s\n", err)
+	}
+	if encoded.Len() != n/2 {
+		t.Fatalf("Encoded %d bytes, expected %d", encoded.Len(), n/2)
+	}
+	dec, _ := NewAlawDecoder(&encoded)
+	if err := dec.SetOutputRate(16000); err != nil {
+		t.Fatalf("SetOutputRate failed: %s\n", err)
+	}
+	out, err := io.ReadAll(dec)
+	if err != nil || len(out) != len(in) {
+		t.Fatalf("Decoded %d bytes, expected %d: %v", len(out), len(in), err)
+	}
+	var sig, noise float64
+	for i := 200; i < n-200; i++ {
+		x := float64(int16(in[2*i]) | int16(in[2*i+1])<<8)
+		y := float64(int16(out[2*i]) | int16(out[2*i+1])<<8)
+		sig += x * x
+		noise += (x - y) * (x - y)
+	}
+	if snr := 10 * math.Log10(sig/noise); snr < 30 {
+		t.Errorf("SNR %.1f dB, expected at least 30 dB", snr)
+	}
+	transcoder, _ := NewUlawEncoder(io.Discard, Alaw)
+	if err := transcoder.SetInputRate(16000); err == nil {
+		t.Error("Input rate accepted for G711 input")
+	}
+}
+
+func TestChannels(t *testing.T) {
+	const n = 800
+	left := make([]byte, 2*n)
+	right := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		x := int16(math.Round(8000 * math.Sin(2*math.Pi*1000*float64(i)/8000)))
+		y := int16(math.Round(4000 * math.Sin(2*math.Pi*440*float64(i)/8000)))
+		left[2*i], left[2*i+1] = byte(x), byte(x>>8)
+		right[2*i], right[2*i+1] = byte(y), byte(y>>8)
+	}
+	stereo, err := Merge([][]byte{left, right}, Lpcm)
+	if err != nil || len(stereo) != 4*n {
+		t.Fatalf("Merge failed: %v\n", err)
+	}
+	split, err := Split(stereo, Lpcm, 2)
+	if err != nil || !bytes.Equal(split[0], left) || !bytes.Equal(split[1], right) {
+		t.Fatalf("Split failed: %v\n", err)
+	}
+	// Channel select and downmix
+	for channel, expected := range map[int][]byte{0: left, 1: right, Downmix: nil} {
+		var encoded bytes.Buffer
+		enc, _ := NewAlawEncoder(&encoded, Lpcm)
+		if err := enc.SetInputChannels(2, channel); err != nil {
+			t.Fatalf("SetInputChannels failed: %s\n", err)
+		}
+		for i := 0; i < len(stereo); i += 333 {
+			end := i + 333
+			if end > len(stereo) {
+				end = len(stereo)
+			}
+			if k, err := enc.Write(stereo[i:end]); err != nil || k != end-i {
+				t.Fatalf("Write failed: %d %v\n", k, err)
+			}
+		}
+		if expected == nil {
+			expected = make([]byte, 2*n)
+			for i := 0; i < n; i++ {
+				x := (int(int16(left[2*i])|int16(left[2*i+1])<<8) + int(int16(right[2*i])|int16(right[2*i+1])<<8)) / 2
+				expected[2*i], expected[2*i+1] = byte(x), byte(x>>8)
+			}
+		}
+		if !bytes.Equal(encoded.Bytes(), EncodeAlaw(expected)) {
+			t.Errorf("Channel %d: encoded data mismatch", channel)
+		}
+	}
+	transcoder, _ := NewUlawEncoder(io.Discard, Alaw)
+	if err := transcoder.SetInputChannels(2, 0); err == nil {
+		t.Error("Input channels accepted for G711 input")
+	}
+	enc, _ := NewUlawEncoder(io.Discard, Lpcm)
+	if err := enc.SetInputChannels(2, 2); err == nil {
+		t.Error("Invalid channel accepted")
+	}
+	// Streaming split and merge of interleaved A-law
+	alaw, _ := Merge([][]byte{EncodeAlaw(left), EncodeAlaw(right)}, Alaw)
+	var l, r bytes.Buffer
+	splitter, err := NewSplitter(Alaw, &l, &r)
+	if err != nil {
+		t.Fatalf("Failed to create Splitter: %s\n", err)
+	}
+	for i := 0; i < len(alaw); i += 161 {
+		end := i + 161
+		if end > len(alaw) {
+			end = len(alaw)
+		}
+		splitter.Write(alaw[i:end])
+	}
+	if !bytes.Equal(l.Bytes(), EncodeAlaw(left)) || !bytes.Equal(r.Bytes(), EncodeAlaw(right)) {
+		t.Error("Splitter output mismatch")
+	}
+	merger, err := NewMerger(Alaw, bytes.NewReader(l.Bytes()), bytes.NewReader(r.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to create Merger: %s\n", err)
+	}
+	merged, err := io.ReadAll(iotest.OneByteReader(merger))
+	if err != nil || !bytes.Equal(merged, alaw) {
+		t.Errorf("Merger output mismatch: %v", err)
+	}
+	// Merging channels of unequal length fills the shorter one with silence
+	merger, _ = NewMerger(Ulaw, bytes.NewReader([]byte{1, 2, 3, 4, 5}), bytes.NewReader([]byte{6, 7}))
+	merged, err = io.ReadAll(iotest.OneByteReader(merger))
+	idle := EncodeUlawFrame(0)
+	if want := []byte{1, 6, 2, 7, 3, idle, 4, idle, 5, idle}; err != nil || !bytes.Equal(merged, want) {
+		t.Errorf("Merger of unequal channels: expected: %v, actual: %v, %v", want, merged, err)
+	}
+	merger, _ = NewMerger(Lpcm, bytes.NewReader([]byte{1, 0}), bytes.NewReader([]byte{2, 0, 3, 0, 4, 0}))
+	merged, err = io.ReadAll(merger)
+	if want := []byte{1, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4, 0}; err != nil || !bytes.Equal(merged, want) {
+		t.Errorf("Merger of unequal channels: expected: %v, actual: %v, %v", want, merged, err)
+	}
+	// Interleaved decoding with resampling keeps the channels apart
+	dec, _ := NewAlawDecoder(bytes.NewReader(alaw))
+	if err := dec.SetChannels(2); err != nil {
+		t.Fatalf("SetChannels failed: %s\n", err)
+	}
+	if err := dec.SetOutputRate(16000); err != nil {
+		t.Fatalf("SetOutputRate failed: %s\n", err)
+	}
+	out, err := io.ReadAll(dec)
+	if err != nil || len(out) != 8*n {
+		t.Fatalf("Decoded %d bytes, expected %d: %v", len(out), 8*n, err)
+	}
+	channels, _ := Split(out, Lpcm, 2)
+	for c, ch := range [][]byte{left, right} {
+		mono, _ := NewAlawDecoder(bytes.NewReader(EncodeAlaw(ch)))
+		mono.SetOutputRate(16000)
+		expected, _ := io.ReadAll(mono)
+		if !bytes.Equal(channels[c], expected) {
+			t.Errorf("Channel %d: resampled data mismatch", c)
+		}
+	}
+}
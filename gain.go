@@ -0,0 +1,63 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+
+	Package g711 implements encoding and decoding of G711 PCM sound data.
+	G.711 is an ITU-T standard for audio companding.
+*/
+
+package g711
+
+import (
+	"errors"
+	"math"
+)
+
+// GainTable is a code to code lookup table that changes the level
+// of A-law or u-law data without decoding it
+type GainTable [256]uint8
+
+// Gain returns a pointer to a GainTable that applies a gain of dB decibels to data
+// of the given law. Samples that exceed the range of the largest segment are saturated.
+func Gain(law int, dB float64) (*GainTable, error) {
+	if math.IsNaN(dB) || math.IsInf(dB, 1) {
+		return nil, errors.New("invalid gain")
+	}
+	var decode func(uint8) int16
+	var encode func(int16) uint8
+	switch law {
+	case Alaw:
+		decode, encode = DecodeAlawFrame, EncodeAlawFrame
+	case Ulaw:
+		decode, encode = DecodeUlawFrame, EncodeUlawFrame
+	default:
+		return nil, errors.New("invalid input format")
+	}
+	g := math.Pow(10, dB/20)
+	t := new(GainTable)
+	for i := range t {
+		frame := math.Round(float64(decode(uint8(i))) * g)
+		if frame > math.MaxInt16 {
+			frame = math.MaxInt16
+		} else if frame < math.MinInt16 {
+			frame = math.MinInt16
+		}
+		t[i] = encode(int16(frame))
+	}
+	return t, nil
+}
+
+// ApplyGain changes the level of G711 data in place
+func (t *GainTable) ApplyGain(buf []byte) {
+	for i := range buf {
+		buf[i] = t[buf[i]]
+	}
+}
+
+// ApplyGainFrame changes the level of a G711 frame
+func (t *GainTable) ApplyGainFrame(frame uint8) uint8 {
+	return t[frame]
+}
@@ -0,0 +1,98 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+
+	Package g711 implements encoding and decoding of G711 PCM sound data.
+	G.711 is an ITU-T standard for audio companding.
+*/
+
+package g711
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"testing"
+)
+
+// Test Gain tables
+func TestGain(t *testing.T) {
+	if _, err := Gain(Lpcm, 6); err == nil {
+		t.Error("Gain accepted LPCM as a law")
+	}
+	for _, dB := range []float64{math.NaN(), math.Inf(1)} {
+		if _, err := Gain(Alaw, dB); err == nil {
+			t.Errorf("Gain accepted a gain of %f dB", dB)
+		}
+	}
+	if mute, err := Gain(Ulaw, math.Inf(-1)); err != nil || DecodeUlawFrame(mute.ApplyGainFrame(EncodeUlawFrame(8000))) != 0 {
+		t.Errorf("Gain of -Inf dB does not mute: %v", err)
+	}
+	for _, law := range []int{Alaw, Ulaw} {
+		decode, encode := DecodeAlawFrame, EncodeAlawFrame
+		if law == Ulaw {
+			decode, encode = DecodeUlawFrame, EncodeUlawFrame
+		}
+		up, _ := Gain(law, 20)
+		// The largest segment saturates
+		if frame := decode(up.ApplyGainFrame(encode(32767))); frame != decode(encode(32767)) {
+			t.Errorf("law %d: positive peak not saturated: %d", law, frame)
+		}
+		if frame := decode(up.ApplyGainFrame(encode(-32768))); frame != decode(encode(-32768)) {
+			t.Errorf("law %d: negative peak not saturated: %d", law, frame)
+		}
+		// A 6dB gain doubles the level, within the quantization step
+		double, _ := Gain(law, 6.0206)
+		for _, x := range []int16{-8000, -1000, 500, 4000, 12000} {
+			want := decode(encode(2 * decode(encode(x))))
+			if frame := decode(double.ApplyGainFrame(encode(x))); frame != want {
+				t.Errorf("law %d: 6dB gain on %d: expected: %d, actual: %d", law, x, want, frame)
+			}
+		}
+		// A gain and its inverse restore the level
+		down, _ := Gain(law, -20)
+		data := []byte{encode(1000), encode(-2000)}
+		down.ApplyGain(data)
+		up.ApplyGain(data)
+		if frame := decode(data[0]); frame < 900 || frame > 1100 {
+			t.Errorf("law %d: gain round trip of 1000: %d", law, frame)
+		}
+	}
+}
+
+// Test the Encoder and Decoder gain option
+func TestSetGain(t *testing.T) {
+	b := new(bytes.Buffer)
+	enc, _ := NewAlawEncoder(b, Lpcm)
+	if err := enc.SetGain(-6.0206); err != nil {
+		t.Fatalf("SetGain failed: %s\n", err)
+	}
+	enc.Write([]byte{0xa0, 0x0f}) // 4000
+	if frame := DecodeAlawFrame(b.Bytes()[0]); frame != 2016 {
+		t.Errorf("Encoder gain: expected: 2016, actual: %d", frame)
+	}
+	dec, _ := NewAlawDecoder(bytes.NewReader([]byte{EncodeAlawFrame(2000)}))
+	dec.SetGain(6.0206)
+	p, _ := io.ReadAll(dec)
+	if frame := int16(p[0]) | int16(p[1])<<8; frame != 4032 {
+		t.Errorf("Decoder gain: expected: 4032, actual: %d", frame)
+	}
+}
+
+// Benchmark ApplyGain
+func BenchmarkApplyGain(b *testing.B) {
+	aData, err := os.ReadFile("testing/speech.alaw")
+	if err != nil {
+		b.Fatalf("Failed to read test data: %s\n", err)
+	}
+	t, _ := Gain(Alaw, -6)
+	b.SetBytes(int64(len(aData)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.ApplyGain(aData)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package generator
+
+import (
+	"errors"
+	"strings"
+)
+
+// Segment is a part of a cadence
+type Segment struct {
+	Freqs    []float64 // Frequencies in Hz, none for silence
+	Duration int       // Duration in ms, 0 for continuous
+}
+
+// Cadence is a sequence of tone segments repeated until the signal ends
+type Cadence struct {
+	Level    float64   // Level of each frequency in dBm0
+	Segments []Segment // Segments of one period
+}
+
+// Plan holds the call progress tones of a country
+type Plan struct {
+	Dial       Cadence // Dial tone
+	Ringback   Cadence // Ringing tone
+	Busy       Cadence // Busy tone
+	Congestion Cadence // Congestion or reorder tone
+}
+
+// Plans holds the call progress tones of some countries, keyed by their ISO 3166 code
+var Plans = map[string]Plan{
+	"us": {
+		Dial:       Cadence{-13, []Segment{{[]float64{350, 440}, 0}}},
+		Ringback:   Cadence{-19, []Segment{{[]float64{440, 480}, 2000}, {nil, 4000}}},
+		Busy:       Cadence{-24, []Segment{{[]float64{480, 620}, 500}, {nil, 500}}},
+		Congestion: Cadence{-24, []Segment{{[]float64{480, 620}, 250}, {nil, 250}}},
+	},
+	"gb": {
+		Dial:       Cadence{-13, []Segment{{[]float64{350, 440}, 0}}},
+		Ringback:   Cadence{-19, []Segment{{[]float64{400, 450}, 400}, {nil, 200}, {[]float64{400, 450}, 400}, {nil, 2000}}},
+		Busy:       Cadence{-13, []Segment{{[]float64{400}, 375}, {nil, 375}}},
+		Congestion: Cadence{-13, []Segment{{[]float64{400}, 400}, {nil, 350}, {[]float64{400}, 225}, {nil, 525}}},
+	},
+	"de": {
+		Dial:       Cadence{-10, []Segment{{[]float64{425}, 0}}},
+		Ringback:   Cadence{-10, []Segment{{[]float64{425}, 1000}, {nil, 4000}}},
+		Busy:       Cadence{-10, []Segment{{[]float64{425}, 480}, {nil, 480}}},
+		Congestion: Cadence{-10, []Segment{{[]float64{425}, 240}, {nil, 240}}},
+	},
+	"fr": {
+		Dial:       Cadence{-10, []Segment{{[]float64{440}, 0}}},
+		Ringback:   Cadence{-10, []Segment{{[]float64{440}, 1500}, {nil, 3500}}},
+		Busy:       Cadence{-10, []Segment{{[]float64{440}, 500}, {nil, 500}}},
+		Congestion: Cadence{-10, []Segment{{[]float64{440}, 250}, {nil, 250}}},
+	},
+	"it": {
+		Dial:       Cadence{-10, []Segment{{[]float64{425}, 200}, {nil, 200}, {[]float64{425}, 600}, {nil, 1000}}},
+		Ringback:   Cadence{-10, []Segment{{[]float64{425}, 1000}, {nil, 4000}}},
+		Busy:       Cadence{-10, []Segment{{[]float64{425}, 500}, {nil, 500}}},
+		Congestion: Cadence{-10, []Segment{{[]float64{425}, 200}, {nil, 200}}},
+	},
+	"au": {
+		Dial:       Cadence{-13, []Segment{{[]float64{400, 425}, 0}}},
+		Ringback:   Cadence{-19, []Segment{{[]float64{400, 450}, 400}, {nil, 200}, {[]float64{400, 450}, 400}, {nil, 2000}}},
+		Busy:       Cadence{-13, []Segment{{[]float64{425}, 375}, {nil, 375}}},
+		Congestion: Cadence{-20, []Segment{{[]float64{425}, 375}, {nil, 375}}},
+	},
+}
+
+// cadence repeats the segments of a Cadence
+type cadence struct {
+	level    float64   // level of each frequency
+	segments []Segment // segments of one period
+	current  Signal    // current segment
+	next     int       // index of the next segment
+	n        int       // current sample
+	length   int       // length in samples, -1 for endless
+}
+
+// Signal returns the cadence lasting ms milliseconds.
+// A duration of 0 or less generates an endless signal.
+func (c Cadence) Signal(ms int) Signal {
+	return &cadence{level: c.Level, segments: c.Segments, length: length(ms)}
+}
+
+func (c *cadence) Sample() (int16, bool) {
+	if (c.length >= 0 && c.n >= c.length) || len(c.segments) == 0 {
+		return 0, false
+	}
+	for {
+		if c.current != nil {
+			if x, ok := c.current.Sample(); ok {
+				c.n++
+				return x, true
+			}
+		}
+		s := c.segments[c.next]
+		c.current = Tone(s.Freqs, c.level, s.Duration)
+		c.next = (c.next + 1) % len(c.segments)
+	}
+}
+
+// Tone returns the cadence of a call progress tone by name, one of
+// dial, ringback, busy and congestion.
+func (p Plan) Tone(name string) (Cadence, error) {
+	switch strings.ToLower(name) {
+	case "dial":
+		return p.Dial, nil
+	case "ringback":
+		return p.Ringback, nil
+	case "busy":
+		return p.Busy, nil
+	case "congestion":
+		return p.Congestion, nil
+	}
+	return Cadence{}, errors.New("unknown call progress tone")
+}
+
+// CallProgress returns a call progress tone of a country from Plans lasting ms milliseconds.
+// A duration of 0 or less generates an endless signal.
+func CallProgress(country, name string, ms int) (Signal, error) {
+	p, ok := Plans[strings.ToLower(country)]
+	if !ok {
+		return nil, errors.New("unknown country")
+	}
+	c, err := p.Tone(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Signal(ms), nil
+}
@@ -0,0 +1,69 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package generator
+
+import (
+	"math"
+
+	"github.com/zaf/g711/internal/dsp"
+)
+
+// fsk is a continuous phase binary FSK signal
+type fsk struct {
+	mark, space float64 // phase step per sample of each frequency
+	amp         float64 // amplitude
+	baud        float64 // bit rate
+	bits        []bool  // bits to send, true for mark
+	phase       float64 // current phase
+	n           int     // current sample
+	length      int     // length in samples
+}
+
+// FSK returns a continuous phase binary FSK signal of bits at baud bits per second,
+// sending the mark frequency for true and the space frequency for false bits, at level dBm0.
+func FSK(mark, space, baud, level float64, bits []bool) Signal {
+	return &fsk{
+		mark:   2 * math.Pi * mark / dsp.SampleRate,
+		space:  2 * math.Pi * space / dsp.SampleRate,
+		amp:    dsp.Amplitude(level),
+		baud:   baud,
+		bits:   bits,
+		length: int(math.Ceil(float64(len(bits)) * dsp.SampleRate / baud)),
+	}
+}
+
+func (f *fsk) Sample() (int16, bool) {
+	if f.n >= f.length {
+		return 0, false
+	}
+	x := f.amp * math.Sin(f.phase)
+	step := f.space
+	if i := int(float64(f.n) * f.baud / dsp.SampleRate); i >= len(f.bits) || f.bits[i] {
+		step = f.mark
+	}
+	f.phase = math.Mod(f.phase+step, 2*math.Pi)
+	f.n++
+	return clip(x), true
+}
+
+// UART returns the bits of asynchronous serial characters of width data bits each, sent
+// least significant bit first after a space start bit and followed by stop mark bits.
+func UART(data []byte, width, stop int) []bool {
+	var bits []bool
+	for _, c := range data {
+		bits = append(bits, false)
+		for i := 0; i < width; i++ {
+			bits = append(bits, c>>i&1 == 1)
+		}
+		for i := 0; i < stop; i++ {
+			bits = append(bits, true)
+		}
+	}
+	return bits
+}
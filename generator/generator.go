@@ -0,0 +1,245 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package generator synthesises test and telephony signals: sine and dual tones,
+DTMF digit strings, call progress tones, noise and idle channel silence.
+
+Signals are read as G711 or LPCM data through a Reader.
+*/
+package generator
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/dtmf"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	samplesPerMs = dsp.SampleRate / 1000
+	pinkRows     = 16 // Voss-McCartney generator rows
+)
+
+// Signal is a source of 16bit linear samples at 8000Hz
+type Signal interface {
+	// Sample returns the next sample of the signal and false once the signal has ended
+	Sample() (int16, bool)
+}
+
+// Reader reads a Signal as G711 or LPCM data
+type Reader struct {
+	format  int     // output format
+	signal  Signal  // signal source
+	samples []int16 // encoding buffer
+}
+
+// NewReader returns a pointer to a Reader that implements an io.Reader.
+// It takes as input the Signal and the output encoding format.
+func NewReader(signal Signal, format int) (*Reader, error) {
+	if signal == nil {
+		return nil, errors.New("signal is nil")
+	}
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid output format")
+	}
+	return &Reader{format: format, signal: signal}, nil
+}
+
+// Read reads up to len(p) bytes of the encoded signal into p, returns
+// the number of bytes read and any error encountered.
+func (r *Reader) Read(p []byte) (int, error) {
+	n := len(p) / pcm.Size(r.format)
+	if n == 0 {
+		return 0, nil
+	}
+	r.samples = r.samples[:0]
+	for len(r.samples) < n {
+		x, ok := r.signal.Sample()
+		if !ok {
+			break
+		}
+		r.samples = append(r.samples, x)
+	}
+	if len(r.samples) == 0 {
+		return 0, io.EOF
+	}
+	return len(pcm.Encode(r.format, r.samples, p[:0])), nil
+}
+
+// IdleCode returns the code an idle A-law or u-law channel carries,
+// the encoding of a zero sample
+func IdleCode(law int) (uint8, error) {
+	switch law {
+	case g711.Alaw:
+		return g711.EncodeAlawFrame(0), nil
+	case g711.Ulaw:
+		return g711.EncodeUlawFrame(0), nil
+	}
+	return 0, errors.New("invalid input format")
+}
+
+// length converts a duration in ms to samples, 0 or less means endless
+func length(ms int) int {
+	if ms <= 0 {
+		return -1
+	}
+	return ms * samplesPerMs
+}
+
+func clip(x float64) int16 {
+	x = math.Round(x)
+	if x > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(x)
+}
+
+// tone is the sum of sine waves of equal amplitude
+type tone struct {
+	step   []float64 // phase step per sample of each frequency
+	amp    float64   // amplitude of each frequency
+	n      int       // current sample
+	length int       // length in samples, -1 for endless
+}
+
+// Sine returns a sine wave of frequency freq in Hz at level dBm0 lasting ms milliseconds.
+// A duration of 0 or less generates an endless signal.
+func Sine(freq, level float64, ms int) Signal {
+	return Tone([]float64{freq}, level, ms)
+}
+
+// DualTone returns the sum of two sine waves, each at level dBm0, lasting ms milliseconds.
+// A duration of 0 or less generates an endless signal.
+func DualTone(f1, f2, level float64, ms int) Signal {
+	return Tone([]float64{f1, f2}, level, ms)
+}
+
+// Tone returns the sum of sine waves of the given frequencies, each at level dBm0, lasting
+// ms milliseconds. A duration of 0 or less generates an endless signal and no frequencies
+// generate silence.
+func Tone(freqs []float64, level float64, ms int) Signal {
+	t := &tone{amp: dsp.Amplitude(level), length: length(ms)}
+	for _, f := range freqs {
+		t.step = append(t.step, 2*math.Pi*f/dsp.SampleRate)
+	}
+	return t
+}
+
+// Silence returns ms milliseconds of silence, which encodes to the idle channel code.
+// A duration of 0 or less generates an endless signal.
+func Silence(ms int) Signal {
+	return Tone(nil, 0, ms)
+}
+
+func (t *tone) Sample() (int16, bool) {
+	if t.length >= 0 && t.n >= t.length {
+		return 0, false
+	}
+	var x float64
+	for _, w := range t.step {
+		x += t.amp * math.Sin(w*float64(t.n))
+	}
+	t.n++
+	return clip(x), true
+}
+
+// DTMF returns the DTMF tones of a digit string, each at level dBm0 per frequency,
+// lasting toneMs milliseconds and followed by pauseMs milliseconds of silence.
+func DTMF(digits string, level float64, toneMs, pauseMs int) (Signal, error) {
+	if toneMs <= 0 || pauseMs < 0 {
+		return nil, errors.New("invalid duration")
+	}
+	var signals []Signal
+	for i := 0; i < len(digits); i++ {
+		low, high, ok := dtmf.Frequencies(digits[i])
+		if !ok {
+			return nil, errors.New("invalid DTMF digit")
+		}
+		signals = append(signals, DualTone(low, high, level, toneMs))
+		if pauseMs > 0 {
+			signals = append(signals, Silence(pauseMs))
+		}
+	}
+	return Sequence(signals...), nil
+}
+
+// sequence plays signals one after the other
+type sequence struct {
+	signals []Signal
+}
+
+// Sequence returns the concatenation of signals
+func Sequence(signals ...Signal) Signal {
+	return &sequence{signals: signals}
+}
+
+func (s *sequence) Sample() (int16, bool) {
+	for len(s.signals) > 0 {
+		if x, ok := s.signals[0].Sample(); ok {
+			return x, true
+		}
+		s.signals = s.signals[1:]
+	}
+	return 0, false
+}
+
+// noise is Gaussian white or pink noise
+type noise struct {
+	rms    float64    // RMS value
+	rng    *rand.Rand // random source
+	pink   bool       // pink or white noise
+	rows   [pinkRows]float64
+	n      int // current sample
+	length int // length in samples, -1 for endless
+}
+
+// WhiteNoise returns Gaussian white noise at level dBm0 lasting ms milliseconds.
+// The seed makes the noise reproducible. A duration of 0 or less generates an endless signal.
+func WhiteNoise(level float64, ms int, seed int64) Signal {
+	return &noise{rms: dsp.Amplitude(level) / math.Sqrt2, rng: rand.New(rand.NewSource(seed)), length: length(ms)}
+}
+
+// PinkNoise returns pink noise at level dBm0 lasting ms milliseconds, made with the
+// Voss-McCartney algorithm. The seed makes the noise reproducible. A duration of 0 or
+// less generates an endless signal.
+func PinkNoise(level float64, ms int, seed int64) Signal {
+	n := &noise{rms: dsp.Amplitude(level) / math.Sqrt2, rng: rand.New(rand.NewSource(seed)), pink: true, length: length(ms)}
+	for i := range n.rows {
+		n.rows[i] = n.rng.NormFloat64()
+	}
+	return n
+}
+
+func (n *noise) Sample() (int16, bool) {
+	if n.length >= 0 && n.n >= n.length {
+		return 0, false
+	}
+	n.n++
+	if !n.pink {
+		return clip(n.rms * n.rng.NormFloat64()), true
+	}
+	// Every row is updated at half the rate of the previous one
+	var sum float64
+	for i := range n.rows {
+		if n.n&(1<<i-1) == 0 {
+			n.rows[i] = n.rng.NormFloat64()
+		}
+		sum += n.rows[i]
+	}
+	sum += n.rng.NormFloat64()
+	return clip(n.rms * sum / math.Sqrt(pinkRows+1)), true
+}
@@ -0,0 +1,136 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package generator
+
+import (
+	"io"
+	"math"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/dtmf"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// samples reads a whole signal as LPCM samples
+func samples(t *testing.T, s Signal) []int16 {
+	r, err := NewReader(s, g711.Lpcm)
+	if err != nil {
+		t.Fatalf("Failed to create Reader: %s\n", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Reading failed: %s\n", err)
+	}
+	return pcm.Decode(g711.Lpcm, data, nil)
+}
+
+// level returns the level of samples in dBm0
+func level(s []int16) float64 {
+	return dsp.Level(dsp.Energy(dsp.Float(s, nil)) / float64(len(s)))
+}
+
+// Test tone and noise levels and durations
+func TestSignals(t *testing.T) {
+	var tests = []struct {
+		name   string
+		signal Signal
+		length int
+		level  float64
+		delta  float64
+	}{
+		{"sine", Sine(1020, -10, 1000), 8000, -10, 0.1},
+		{"dual tone", DualTone(350, 440, -13, 500), 4000, -10, 0.1},
+		{"white noise", WhiteNoise(-20, 2000, 1), 16000, -20, 0.5},
+		{"pink noise", PinkNoise(-20, 2000, 1), 16000, -20, 1.5},
+	}
+	for _, tc := range tests {
+		s := samples(t, tc.signal)
+		if len(s) != tc.length {
+			t.Errorf("%s: expected: %d samples, actual: %d", tc.name, tc.length, len(s))
+		}
+		if l := level(s); math.Abs(l-tc.level) > tc.delta {
+			t.Errorf("%s: expected: %.1f dBm0, actual: %.1f dBm0", tc.name, tc.level, l)
+		}
+	}
+	if s := samples(t, WhiteNoise(-20, 100, 7)); s[10] != samples(t, WhiteNoise(-20, 100, 7))[10] {
+		t.Error("Noise is not reproducible")
+	}
+}
+
+// Test DTMF generation against the detector
+func TestDTMF(t *testing.T) {
+	s, err := DTMF("159#D", -10, 80, 60)
+	if err != nil {
+		t.Fatalf("DTMF failed: %s\n", err)
+	}
+	r, _ := NewReader(s, g711.Alaw)
+	data, _ := io.ReadAll(r)
+	d, _ := dtmf.NewDetector(g711.Alaw, dtmf.DefaultConfig)
+	var digits string
+	for _, e := range append(d.Process(data), d.Flush()...) {
+		digits += string(e.Digit)
+	}
+	if digits != "159#D" {
+		t.Errorf("Expected: 159#D, actual: %s", digits)
+	}
+	if _, err = DTMF("12x", -10, 80, 60); err == nil {
+		t.Error("DTMF accepted an invalid digit")
+	}
+}
+
+// Test call progress cadences
+func TestCallProgress(t *testing.T) {
+	for country, plan := range Plans {
+		for _, name := range []string{"dial", "ringback", "busy", "congestion"} {
+			s, err := CallProgress(country, name, 6000)
+			if err != nil {
+				t.Fatalf("%s %s: %s\n", country, name, err)
+			}
+			if n := len(samples(t, s)); n != 48000 {
+				t.Errorf("%s %s: expected: 48000 samples, actual: %d", country, name, n)
+			}
+		}
+		// Every segment of a period takes its place in the cadence
+		var period int
+		for _, seg := range plan.Busy.Segments {
+			period += seg.Duration * 8
+		}
+		s := samples(t, plan.Busy.Signal(period/8))
+		var offset int
+		for _, seg := range plan.Busy.Segments {
+			e := dsp.Energy(dsp.Float(s[offset:offset+seg.Duration*8], nil))
+			if (e == 0) != (len(seg.Freqs) == 0) {
+				t.Errorf("%s busy: segment at %d has energy %f", country, offset, e)
+			}
+			offset += seg.Duration * 8
+		}
+	}
+	if _, err := CallProgress("xx", "busy", 1000); err == nil {
+		t.Error("CallProgress accepted an unknown country")
+	}
+}
+
+// Test idle channel codes
+func TestIdleCode(t *testing.T) {
+	if c, _ := IdleCode(g711.Alaw); c != 0xD5 {
+		t.Errorf("A-law idle code: expected: 0xD5, actual: 0x%02x", c)
+	}
+	if c, _ := IdleCode(g711.Ulaw); c != 0xFF {
+		t.Errorf("u-law idle code: expected: 0xFF, actual: 0x%02x", c)
+	}
+	r, _ := NewReader(Silence(10), g711.Ulaw)
+	data, _ := io.ReadAll(r)
+	for _, c := range data {
+		if c != 0xFF {
+			t.Fatalf("u-law silence: expected: 0xFF, actual: 0x%02x", c)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package dsp holds the signal processing building blocks shared by the
+g711 sub-packages.
+*/
+package dsp
+
+import "math"
+
+const (
+	// SampleRate is the G711 sampling rate in Hz
+	SampleRate = 8000
+	// RefRMS is the RMS value in 16bit LPCM of a sine wave at 0dBm0,
+	// 3.14dB below the A-law overload point
+	RefRMS = 16112.0
+)
+
+// Goertzel computes the power of a single frequency over blocks of samples
+type Goertzel struct {
+	Freq  float64 // Frequency in Hz
+	coeff float64 // 2cos(2πf/fs)
+}
+
+// NewGoertzel returns a Goertzel filter for the frequency freq in Hz
+func NewGoertzel(freq float64) Goertzel {
+	return Goertzel{Freq: freq, coeff: 2 * math.Cos(2*math.Pi*freq/SampleRate)}
+}
+
+// Power returns the squared magnitude of the DFT of s at the filter frequency.
+// A sine wave of amplitude A has a power of (A*len(s)/2)^2.
+func (g Goertzel) Power(s []float64) float64 {
+	var s1, s2 float64
+	for _, x := range s {
+		s1, s2 = x+g.coeff*s1-s2, s1
+	}
+	return s1*s1 + s2*s2 - g.coeff*s1*s2
+}
+
+// Energy returns the sum of squares of s
+func Energy(s []float64) float64 {
+	var e float64
+	for _, x := range s {
+		e += x * x
+	}
+	return e
+}
+
+// ToneEnergy returns the energy that the Goertzel power p over n samples
+// corresponds to, for comparison with the block energy
+func ToneEnergy(p float64, n int) float64 {
+	return 2 * p / float64(n)
+}
+
+// Amplitude returns the amplitude in 16bit LPCM of a sine wave at level dBm0
+func Amplitude(level float64) float64 {
+	return math.Sqrt2 * RefRMS * math.Pow(10, level/20)
+}
+
+// Level returns the level in dBm0 of a signal with the given mean power per sample
+func Level(power float64) float64 {
+	if power <= 0 {
+		return math.Inf(-1)
+	}
+	return 10 * math.Log10(power/(RefRMS*RefRMS))
+}
+
+// DB converts a decibel value to a power ratio
+func DB(db float64) float64 {
+	return math.Pow(10, db/10)
+}
+
+// Float converts 16bit linear samples to float64, appending them to f
+func Float(s []int16, f []float64) []float64 {
+	for _, x := range s {
+		f = append(f, float64(x))
+	}
+	return f
+}
+
+// Framer splits a stream of samples into blocks of a fixed size
+type Framer struct {
+	Size int       // Block size in samples
+	buf  []float64 // incomplete block
+}
+
+// Push appends samples to the stream and calls fn for every complete block
+func (f *Framer) Push(s []int16, fn func(block []float64)) {
+	for len(s) > 0 {
+		n := f.Size - len(f.buf)
+		if n > len(s) {
+			n = len(s)
+		}
+		f.buf = Float(s[:n], f.buf)
+		s = s[n:]
+		if len(f.buf) == f.Size {
+			fn(f.buf)
+			f.buf = f.buf[:0]
+		}
+	}
+}
+
+// Reset discards any incomplete block
+func (f *Framer) Reset() {
+	f.buf = f.buf[:0]
+}
@@ -0,0 +1,129 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+// Test the Goertzel power of a sine wave against its amplitude
+func TestGoertzel(t *testing.T) {
+	s := make([]float64, 200)
+	for i := range s {
+		s[i] = 1000 * math.Sin(2*math.Pi*1000*float64(i)/SampleRate)
+	}
+	g := NewGoertzel(1000)
+	if p, want := g.Power(s), math.Pow(1000*200/2, 2); math.Abs(p-want) > want*1e-6 {
+		t.Errorf("Goertzel power: expected: %f, actual: %f", want, p)
+	}
+	if e := ToneEnergy(g.Power(s), len(s)); math.Abs(e-Energy(s)) > e*1e-6 {
+		t.Errorf("Tone energy: expected: %f, actual: %f", Energy(s), e)
+	}
+	if p := NewGoertzel(2000).Power(s); p > 1e-6 {
+		t.Errorf("Goertzel power at 2000Hz: %f", p)
+	}
+}
+
+// Test splitting samples into blocks
+func TestFramer(t *testing.T) {
+	f := Framer{Size: 3}
+	var blocks int
+	for _, s := range [][]int16{{1}, {2, 3, 4, 5}, {6, 7}} {
+		f.Push(s, func(block []float64) {
+			blocks++
+			if block[0] != float64(3*blocks-2) {
+				t.Errorf("Block %d starts with %f", blocks, block[0])
+			}
+		})
+	}
+	if blocks != 2 {
+		t.Errorf("Expected: 2 blocks, actual: %d", blocks)
+	}
+}
+
+// Test tracking tones through short dropouts
+func TestToneTracker(t *testing.T) {
+	tr := ToneTracker{Size: 10, Gap: 2}
+	var tones []ToneRun
+	for _, c := range []byte("xx1110111001122200") {
+		if c == 'x' || c == '0' {
+			c = 0
+		}
+		if r, ok := tr.Push(c, 3); ok {
+			tones = append(tones, r)
+		}
+	}
+	if r, ok := tr.Flush(); ok {
+		tones = append(tones, r)
+	}
+	want := []ToneRun{{'1', 20, 90}, {'2', 130, 160}}
+	if len(tones) != len(want) {
+		t.Fatalf("Expected: %v, actual: %v", want, tones)
+	}
+	for i := range want {
+		if tones[i] != want[i] {
+			t.Errorf("Tone %d: expected: %v, actual: %v", i, want[i], tones[i])
+		}
+	}
+}
+
+// Test the UART with an ideal bit stream of 7 samples per bit
+func TestUART(t *testing.T) {
+	const period = 7
+	u := NewUART(SampleRate/period, 8)
+	line := []bool{true, true}
+	for _, c := range []byte{0x80, 0x55, 0xff} {
+		line = append(line, false)
+		for i := 0; i < 8; i++ {
+			line = append(line, c>>i&1 == 1)
+		}
+		line = append(line, true, true)
+	}
+	// A missing stop bit
+	line = append(line, false, false, false, false, false, false, false, false, false, false, false, true)
+	var got []byte
+	for _, bit := range line {
+		for i := 0; i < period; i++ {
+			if c, ok := u.Sample(bit); ok {
+				got = append(got, c)
+			}
+		}
+	}
+	if string(got) != "\x80\x55\xff" {
+		t.Errorf("Expected: 80 55 ff, actual: % x", got)
+	}
+}
+
+// Test the FFT against a direct DFT and its inverse
+func TestFFT(t *testing.T) {
+	const n = 64
+	x := make([]complex128, n)
+	for i := range x {
+		x[i] = complex(math.Sin(float64(i)*0.7)+float64(i%5), math.Cos(float64(i)*1.3))
+	}
+	y := append([]complex128{}, x...)
+	FFT(y)
+	for k := 0; k < n; k++ {
+		var want complex128
+		for i := range x {
+			s, c := math.Sincos(-2 * math.Pi * float64(k*i) / n)
+			want += x[i] * complex(c, s)
+		}
+		if d := y[k] - want; math.Hypot(real(d), imag(d)) > 1e-9 {
+			t.Errorf("Bin %d: expected: %v, actual: %v", k, want, y[k])
+		}
+	}
+	IFFT(y)
+	for i := range x {
+		if d := y[i] - x[i]; math.Hypot(real(d), imag(d)) > 1e-12 {
+			t.Errorf("Sample %d: expected: %v, actual: %v", i, x[i], y[i])
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package dsp
+
+import (
+	"math"
+	"math/bits"
+)
+
+// FFT computes in place the discrete Fourier transform of x.
+// The length of x must be a power of 2.
+func FFT(x []complex128) {
+	fft(x, -1)
+}
+
+// IFFT computes in place the inverse discrete Fourier transform of x, scaled by 1/len(x).
+// The length of x must be a power of 2.
+func IFFT(x []complex128) {
+	fft(x, 1)
+	k := complex(1/float64(len(x)), 0)
+	for i := range x {
+		x[i] *= k
+	}
+}
+
+// fft is an iterative radix-2 transform, sign is the sign of the exponent
+func fft(x []complex128, sign float64) {
+	n := len(x)
+	if n&(n-1) != 0 {
+		panic("FFT length is not a power of 2")
+	}
+	if n < 2 {
+		return
+	}
+	shift := 64 - bits.Len(uint(n-1))
+	for i := range x {
+		if j := int(bits.Reverse64(uint64(i)) >> shift); j > i {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		s, c := math.Sincos(sign * 2 * math.Pi / float64(size))
+		step := complex(c, s)
+		half := size / 2
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < half; k++ {
+				a, b := x[start+k], w*x[start+k+half]
+				x[start+k], x[start+k+half] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package dsp
+
+import "math"
+
+// FSK is a non-coherent binary FSK demodulator. It correlates the signal with the
+// mark and space frequencies over a sliding window of about one bit.
+type FSK struct {
+	mark, space complex128   // per sample phase rotation of each frequency
+	markOsc     complex128   // mark local oscillator
+	spaceOsc    complex128   // space local oscillator
+	markBuf     []complex128 // mark correlator window
+	spaceBuf    []complex128 // space correlator window
+	energyBuf   []float64    // signal energy window
+	markSum     complex128
+	spaceSum    complex128
+	energy      float64
+	pos         int     // window position
+	n           int     // samples since the last oscillator normalisation
+	Mark, Space float64 // Power of the mark and space correlators for the last sample
+	Energy      float64 // Signal energy in the window for the last sample
+}
+
+// NewFSK returns an FSK demodulator for the mark and space frequencies in Hz
+// and a correlation window of the given number of samples
+func NewFSK(mark, space float64, window int) *FSK {
+	return &FSK{
+		mark:      rotation(mark),
+		space:     rotation(space),
+		markOsc:   1,
+		spaceOsc:  1,
+		markBuf:   make([]complex128, window),
+		spaceBuf:  make([]complex128, window),
+		energyBuf: make([]float64, window),
+	}
+}
+
+func rotation(freq float64) complex128 {
+	s, c := math.Sincos(-2 * math.Pi * freq / SampleRate)
+	return complex(c, s)
+}
+
+// Demod demodulates a sample and returns the difference of the mark and space
+// correlator power, positive for mark and negative for space
+func (f *FSK) Demod(x float64) float64 {
+	m := complex(x, 0) * f.markOsc
+	s := complex(x, 0) * f.spaceOsc
+	f.markSum += m - f.markBuf[f.pos]
+	f.spaceSum += s - f.spaceBuf[f.pos]
+	f.energy += x*x - f.energyBuf[f.pos]
+	f.markBuf[f.pos], f.spaceBuf[f.pos], f.energyBuf[f.pos] = m, s, x*x
+	f.pos = (f.pos + 1) % len(f.markBuf)
+	f.markOsc *= f.mark
+	f.spaceOsc *= f.space
+	if f.n++; f.n == 1024 {
+		// Keep the oscillators on the unit circle and the sums free of rounding drift
+		f.markOsc /= complex(abs(f.markOsc), 0)
+		f.spaceOsc /= complex(abs(f.spaceOsc), 0)
+		f.markSum, f.spaceSum, f.energy = 0, 0, 0
+		for i := range f.markBuf {
+			f.markSum += f.markBuf[i]
+			f.spaceSum += f.spaceBuf[i]
+			f.energy += f.energyBuf[i]
+		}
+		f.n = 0
+	}
+	f.Mark = real(f.markSum)*real(f.markSum) + imag(f.markSum)*imag(f.markSum)
+	f.Space = real(f.spaceSum)*real(f.spaceSum) + imag(f.spaceSum)*imag(f.spaceSum)
+	f.Energy = f.energy
+	return f.Mark - f.Space
+}
+
+// Carrier returns the fraction of the signal energy in the window that is at
+// the mark or space frequency
+func (f *FSK) Carrier() float64 {
+	if f.Energy <= 0 {
+		return 0
+	}
+	return ToneEnergy(math.Max(f.Mark, f.Space), len(f.markBuf)) / f.Energy
+}
+
+// Reset discards the demodulator state
+func (f *FSK) Reset() {
+	for i := range f.markBuf {
+		f.markBuf[i], f.spaceBuf[i], f.energyBuf[i] = 0, 0, 0
+	}
+	f.markSum, f.spaceSum, f.energy, f.pos, f.n = 0, 0, 0, 0, 0
+	f.markOsc, f.spaceOsc = 1, 1
+}
+
+func abs(c complex128) float64 {
+	return math.Hypot(real(c), imag(c))
+}
+
+// BitClock recovers the bit timing of a synchronous bit stream from
+// the transitions of the demodulated signal
+type BitClock struct {
+	step  float64 // bit period fraction per sample
+	phase float64 // position within the current bit, 0 at the bit boundary
+	last  bool    // previous demodulated value
+}
+
+// NewBitClock returns a BitClock for the given bit rate
+func NewBitClock(baud float64) *BitClock {
+	return &BitClock{step: baud / SampleRate}
+}
+
+// Sample advances the clock by one sample of the demodulated bit value and
+// reports whether this sample is the middle of a bit
+func (c *BitClock) Sample(bit bool) bool {
+	if bit != c.last {
+		// Pull the clock towards the transition
+		if c.phase < 0.5 {
+			c.phase -= c.phase / 2
+		} else {
+			c.phase += (1 - c.phase) / 2
+		}
+		c.last = bit
+	}
+	prev := c.phase
+	c.phase += c.step
+	if c.phase >= 1 {
+		c.phase--
+	}
+	return prev < 0.5 && c.phase >= 0.5
+}
@@ -0,0 +1,86 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package dsp
+
+// ToneRun is a tone found by a ToneTracker
+type ToneRun struct {
+	Class byte  // Class of the blocks of the tone
+	Start int64 // Sample offset of the start of the tone
+	End   int64 // Sample offset of the end of the last block of the tone
+}
+
+// ToneTracker turns the classes of consecutive blocks into tones of a minimum
+// duration. A tone starts after a run of blocks of the same class and ends after
+// a gap of blocks without it. Class 0 marks a block without a tone.
+type ToneTracker struct {
+	Size      int     // Block size in samples
+	Gap       int     // Blocks without the tone that end it
+	offset    int64   // sample offset of the next block
+	candidate byte    // class of the current run of blocks
+	run       int     // length of the current run
+	runStart  int64   // start of the current run
+	active    ToneRun // tone in progress, class 0 without one
+	misses    int     // blocks since the active tone was last seen
+}
+
+// Push adds the class of the next block, that starts a tone once its run lasts
+// need blocks. It returns the tone that ended and true if there is one.
+func (t *ToneTracker) Push(c byte, need int) (ToneRun, bool) {
+	if c != 0 && c == t.candidate {
+		t.run++
+	} else {
+		t.candidate, t.run, t.runStart = c, 1, t.offset
+	}
+	t.offset += int64(t.Size)
+	var ended ToneRun
+	var ok bool
+	if t.active.Class != 0 {
+		if c == t.active.Class {
+			t.misses = 0
+			t.active.End = t.offset
+			return ended, false
+		}
+		t.misses++
+		if t.misses < t.Gap {
+			return ended, false
+		}
+		ended, ok = t.Flush()
+	}
+	if c != 0 && t.run >= need {
+		t.active, t.misses = ToneRun{Class: c, Start: t.runStart, End: t.offset}, 0
+	}
+	return ended, ok
+}
+
+// Flush ends the tone in progress and returns it and true if there is one
+func (t *ToneTracker) Flush() (ToneRun, bool) {
+	r := t.active
+	t.active.Class = 0
+	return r, r.Class != 0
+}
+
+// Active returns the class of the tone in progress or 0 if there is none
+func (t *ToneTracker) Active() byte {
+	return t.active.Class
+}
+
+// Reset discards the ToneTracker state
+func (t *ToneTracker) Reset() {
+	t.offset, t.candidate, t.run, t.misses, t.active = 0, 0, 0, 0, ToneRun{}
+}
+
+// CompleteBlocks returns the number of complete blocks of size samples that fit
+// in any interval of ms milliseconds, at least one
+func CompleteBlocks(ms, size int) int {
+	n := (ms*SampleRate/1000 - size + 1) / size
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
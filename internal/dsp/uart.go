@@ -0,0 +1,67 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package dsp
+
+// UART receives asynchronous serial characters from a demodulated bit stream:
+// a space start bit, the data bits least significant first and a mark stop bit.
+type UART struct {
+	width  int     // data bits per character
+	period float64 // samples per bit
+	active bool    // receiving a character
+	idle   bool    // the line was at mark since the last character
+	t      float64 // samples since the start bit edge
+	next   int     // next bit to sample, 0 is the start bit
+	char   byte    // character being received
+}
+
+// NewUART returns a UART receiver for the given bit rate and data bits per character
+func NewUART(baud float64, width int) *UART {
+	return &UART{width: width, period: SampleRate / baud}
+}
+
+// Sample advances the receiver by one sample of the demodulated line, true for mark.
+// It returns a character and true when its stop bit is received. Characters with
+// a missing stop bit are discarded.
+func (u *UART) Sample(mark bool) (byte, bool) {
+	if !u.active {
+		if mark {
+			u.idle = true
+		} else if u.idle {
+			u.active, u.t, u.next, u.char = true, 0, 0, 0
+		}
+		return 0, false
+	}
+	u.t++
+	if u.t < (float64(u.next)+0.5)*u.period {
+		return 0, false
+	}
+	switch {
+	case u.next == 0:
+		if mark {
+			// A glitch rather than a start bit
+			u.active = false
+			return 0, false
+		}
+	case u.next <= u.width:
+		if mark {
+			u.char |= 1 << (u.next - 1)
+		}
+	default:
+		u.active = false
+		u.idle = mark
+		return u.char, mark
+	}
+	u.next++
+	return 0, false
+}
+
+// Reset discards the receiver state and waits for the line to return to mark
+func (u *UART) Reset() {
+	u.active, u.idle = false, false
+}
@@ -0,0 +1,146 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package pcm converts between the g711 data formats and 16bit linear samples.
+It is shared by the g711 sub-packages that process audio in the sample domain.
+*/
+package pcm
+
+import (
+	"errors"
+	"io"
+
+	"github.com/zaf/g711"
+)
+
+// Valid reports whether format is one of the g711 data formats
+func Valid(format int) bool {
+	return format == g711.Alaw || format == g711.Ulaw || format == g711.Lpcm
+}
+
+// Size returns the number of bytes per sample of a format
+func Size(format int) int {
+	if format == g711.Lpcm {
+		return 2
+	}
+	return 1
+}
+
+// Decode converts data in the given format to 16bit linear samples.
+// A trailing incomplete LPCM sample is ignored.
+func Decode(format int, data []byte, s []int16) []int16 {
+	switch format {
+	case g711.Alaw:
+		for _, b := range data {
+			s = append(s, g711.DecodeAlawFrame(b))
+		}
+	case g711.Ulaw:
+		for _, b := range data {
+			s = append(s, g711.DecodeUlawFrame(b))
+		}
+	default:
+		for i := 0; i+1 < len(data); i += 2 {
+			s = append(s, int16(data[i])|int16(data[i+1])<<8)
+		}
+	}
+	return s
+}
+
+// Encode converts 16bit linear samples to data in the given format
+func Encode(format int, s []int16, data []byte) []byte {
+	switch format {
+	case g711.Alaw:
+		for _, x := range s {
+			data = append(data, g711.EncodeAlawFrame(x))
+		}
+	case g711.Ulaw:
+		for _, x := range s {
+			data = append(data, g711.EncodeUlawFrame(x))
+		}
+	default:
+		for _, x := range s {
+			data = append(data, byte(x), byte(x>>8))
+		}
+	}
+	return data
+}
+
+// Reader reads 16bit linear samples from a source in any of the g711 data formats
+type Reader struct {
+	format  int       // source format
+	source  io.Reader // source data
+	buf     []byte    // read buffer
+	pending int       // bytes of an incomplete LPCM sample kept at the start of buf
+}
+
+// NewReader returns a Reader that decodes samples of the given format from reader
+func NewReader(reader io.Reader, format int) (*Reader, error) {
+	if reader == nil {
+		return nil, errors.New("io.Reader is nil")
+	}
+	if !Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	return &Reader{format: format, source: reader}, nil
+}
+
+// Read reads up to len(s) samples into s, returns the number
+// of samples read and any error encountered.
+func (r *Reader) Read(s []int16) (int, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	size := len(s) * Size(r.format)
+	if len(r.buf) < size {
+		buf := make([]byte, size)
+		copy(buf, r.buf)
+		r.buf = buf
+	}
+	n, err := r.source.Read(r.buf[r.pending:size])
+	n += r.pending
+	r.pending = 0
+	i := len(Decode(r.format, r.buf[:n], s[:0]))
+	if r.format == g711.Lpcm && n%2 != 0 && err == nil {
+		r.buf[0] = r.buf[n-1]
+		r.pending = 1
+	}
+	return i, err
+}
+
+// ReadFull reads exactly len(s) samples from r into s. The error is io.EOF only
+// if no samples were read and io.ErrUnexpectedEOF if fewer than len(s) were read.
+func ReadFull(r *Reader, s []int16) (n int, err error) {
+	for n < len(s) && err == nil {
+		var i int
+		i, err = r.Read(s[n:])
+		n += i
+	}
+	if n == len(s) {
+		err = nil
+	} else if n > 0 && err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// ReadAll reads samples from r until EOF
+func ReadAll(r *Reader) ([]int16, error) {
+	var s []int16
+	buf := make([]int16, 4096)
+	for {
+		n, err := r.Read(buf)
+		s = append(s, buf[:n]...)
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return s, err
+		}
+	}
+}
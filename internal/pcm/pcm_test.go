@@ -0,0 +1,75 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package pcm
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/zaf/g711"
+)
+
+// Test reading LPCM samples split across reads
+func TestReader(t *testing.T) {
+	samples := []int16{0, 1, -1, 32767, -32768, 1234, -4321}
+	data := Encode(g711.Lpcm, samples, nil)
+	r, _ := NewReader(iotest.OneByteReader(bytes.NewReader(data)), g711.Lpcm)
+	s, err := ReadAll(r)
+	if err != nil {
+		t.Fatalf("Reading failed: %s\n", err)
+	}
+	if len(s) != len(samples) {
+		t.Fatalf("Expected: %d samples, actual: %d", len(samples), len(s))
+	}
+	for i := range s {
+		if s[i] != samples[i] {
+			t.Errorf("Sample %d: expected: %d, actual: %d", i, samples[i], s[i])
+		}
+	}
+	for _, format := range []int{g711.Alaw, g711.Ulaw} {
+		r, _ = NewReader(bytes.NewReader(Encode(format, samples, nil)), format)
+		if s, _ = ReadAll(r); len(s) != len(samples) {
+			t.Errorf("Format %d: expected: %d samples, actual: %d", format, len(samples), len(s))
+		}
+	}
+}
+
+// Test processing LPCM data split across reads and writes
+func TestProcess(t *testing.T) {
+	data := Encode(g711.Lpcm, []int16{1, -2, 300, -4000, 5}, nil)
+	data = append(data, 7) // trailing odd byte
+	negate := func(b []byte) error {
+		if len(b)%2 != 0 {
+			t.Fatalf("Partial sample processed: %d bytes", len(b))
+		}
+		s := Decode(g711.Lpcm, b, nil)
+		for i := range s {
+			s[i] = -s[i]
+		}
+		Encode(g711.Lpcm, s, b[:0])
+		return nil
+	}
+	want := Encode(g711.Lpcm, []int16{-1, 2, -300, 4000, -5}, nil)
+	want = append(want, 7)
+	r := NewProcessReader(iotest.OneByteReader(bytes.NewReader(data)), g711.Lpcm, negate)
+	got, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Reader: expected: %v, actual: %v, %v", want, got, err)
+	}
+	var out bytes.Buffer
+	w := NewProcessWriter(&out, g711.Lpcm, negate)
+	for _, b := range data {
+		w.Write([]byte{b})
+	}
+	if !bytes.Equal(out.Bytes(), want[:len(want)-1]) {
+		t.Errorf("Writer: expected: %v, actual: %v", want[:len(want)-1], out.Bytes())
+	}
+}
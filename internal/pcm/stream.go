@@ -0,0 +1,107 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package pcm
+
+import "io"
+
+// ProcessReader applies a function that changes data in place to the data read
+// from an io.Reader, in whole samples
+type ProcessReader struct {
+	format  int // data format
+	process func([]byte) error
+	source  io.Reader
+	buf     []byte // read buffer
+	out     []byte // processed data not yet returned
+	part    []byte // bytes of an incomplete sample
+	err     error  // error of the source, returned once out is drained
+}
+
+// NewProcessReader returns a pointer to a ProcessReader that applies process to
+// data of the given format read from r
+func NewProcessReader(r io.Reader, format int, process func([]byte) error) *ProcessReader {
+	return &ProcessReader{format: format, process: process, source: r}
+}
+
+// Read reads data from the source and processes it. It returns
+// the number of bytes read and any error encountered.
+func (r *ProcessReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(r.out) == 0 && r.err == nil {
+		r.fill(len(p))
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	if len(r.out) == 0 && r.err != nil {
+		return n, r.err
+	}
+	return n, nil
+}
+
+// fill reads at least a whole sample, or up to the end of the stream, and processes it
+func (r *ProcessReader) fill(n int) {
+	size := Size(r.format)
+	if n < size {
+		n = size
+	}
+	if cap(r.buf) < n {
+		r.buf = make([]byte, n)
+	}
+	r.buf = r.buf[:n]
+	m := copy(r.buf, r.part)
+	for m < size && r.err == nil {
+		var k int
+		k, r.err = r.source.Read(r.buf[m:])
+		m += k
+	}
+	whole := m - m%size
+	if err := r.process(r.buf[:whole]); err != nil {
+		r.out, r.err = nil, err
+		return
+	}
+	r.part = append(r.part[:0], r.buf[whole:m]...)
+	r.out = r.buf[:whole]
+	if r.err != nil {
+		// A trailing odd byte at the end of the stream passes through unchanged
+		r.out = r.buf[:m]
+		r.part = r.part[:0]
+	}
+}
+
+// ProcessWriter applies a function that changes data in place to the data written
+// to an io.Writer, in whole samples
+type ProcessWriter struct {
+	format  int // data format
+	process func([]byte) error
+	dest    io.Writer
+	buf     []byte
+	part    []byte // bytes of an incomplete sample
+}
+
+// NewProcessWriter returns a pointer to a ProcessWriter that applies process to
+// data of the given format written to w
+func NewProcessWriter(w io.Writer, format int, process func([]byte) error) *ProcessWriter {
+	return &ProcessWriter{format: format, process: process, dest: w}
+}
+
+// Write processes the whole samples of p and writes them to the destination.
+// It returns the number of bytes written and any error encountered.
+func (w *ProcessWriter) Write(p []byte) (int, error) {
+	w.buf = append(append(w.buf[:0], w.part...), p...)
+	whole := len(w.buf) - len(w.buf)%Size(w.format)
+	w.part = append(w.part[:0], w.buf[whole:]...)
+	if err := w.process(w.buf[:whole]); err != nil {
+		return 0, err
+	}
+	if _, err := w.dest.Write(w.buf[:whole]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
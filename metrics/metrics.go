@@ -0,0 +1,189 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package metrics implements objective quality metrics between a reference
+and a test audio stream, such as an LPCM stream and its G.711 round trip.
+
+The metrics are collected by streaming accumulators and the streams can be
+in any of the g711 data formats.
+*/
+package metrics
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	// Segmental SNR parameters
+	SegmentSize = 160 // Default segment length, 20ms at 8000Hz
+	SegmentMin  = -10 // Lower bound of the SNR of a segment in dB
+	SegmentMax  = 35  // Upper bound of the SNR of a segment in dB
+
+	chunk = 4096 // samples per read
+)
+
+// Result holds the metrics between a reference and a test signal
+type Result struct {
+	Samples     int     // Number of samples compared
+	SNR         float64 // Signal to noise ratio in dB
+	SegSNR      float64 // Segmental signal to noise ratio in dB
+	MSE         float64 // Mean squared error
+	MaxAbsError int     // Largest absolute sample error
+}
+
+// Accumulator collects the error between a reference and a test signal sample by sample
+type Accumulator struct {
+	segmentSize int     // samples per segment
+	samples     int     // total samples
+	signal      float64 // total reference energy
+	noise       float64 // total error energy
+	maxError    int     // largest absolute error
+	segSignal   float64 // reference energy of the current segment
+	segNoise    float64 // error energy of the current segment
+	segSamples  int     // samples in the current segment
+	segments    int     // completed segments
+	segSum      float64 // sum of the SNR of the completed segments
+}
+
+// NewAccumulator returns a pointer to an Accumulator that computes the segmental SNR
+// over segments of the given number of samples.
+func NewAccumulator(segmentSize int) (*Accumulator, error) {
+	if segmentSize <= 0 {
+		return nil, errors.New("invalid segment size")
+	}
+	return &Accumulator{segmentSize: segmentSize}, nil
+}
+
+// Reset discards the Accumulator state. This permits reusing an Accumulator rather than allocating a new one.
+func (a *Accumulator) Reset() {
+	*a = Accumulator{segmentSize: a.segmentSize}
+}
+
+// Add adds pairs of reference and test samples to the Accumulator.
+// Samples beyond the length of the shorter slice are ignored.
+func (a *Accumulator) Add(ref, test []int16) {
+	if len(test) < len(ref) {
+		ref = ref[:len(test)]
+	}
+	for i, r := range ref {
+		e := int(test[i]) - int(r)
+		if e < 0 {
+			e = -e
+		}
+		if e > a.maxError {
+			a.maxError = e
+		}
+		s, n := float64(r)*float64(r), float64(e)*float64(e)
+		a.signal += s
+		a.noise += n
+		a.segSignal += s
+		a.segNoise += n
+		a.segSamples++
+		if a.segSamples == a.segmentSize {
+			a.segSum += segmentSNR(a.segSignal, a.segNoise)
+			a.segments++
+			a.segSignal, a.segNoise, a.segSamples = 0, 0, 0
+		}
+	}
+	a.samples += len(ref)
+}
+
+// Result returns the metrics of the samples added so far.
+// A trailing incomplete segment is not part of the segmental SNR.
+func (a *Accumulator) Result() Result {
+	r := Result{Samples: a.samples, MaxAbsError: a.maxError}
+	if a.samples == 0 {
+		return r
+	}
+	r.MSE = a.noise / float64(a.samples)
+	r.SNR = snr(a.signal, a.noise)
+	if a.segments > 0 {
+		r.SegSNR = a.segSum / float64(a.segments)
+	}
+	return r
+}
+
+// Compare reads a reference and a test stream until either of them ends
+// and returns the metrics between them.
+func Compare(ref io.Reader, refFormat int, test io.Reader, testFormat int) (Result, error) {
+	refReader, err := pcm.NewReader(ref, refFormat)
+	if err != nil {
+		return Result{}, err
+	}
+	testReader, err := pcm.NewReader(test, testFormat)
+	if err != nil {
+		return Result{}, err
+	}
+	a, _ := NewAccumulator(SegmentSize)
+	r := make([]int16, chunk)
+	t := make([]int16, chunk)
+	for {
+		n, err := pcm.ReadFull(refReader, r)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return a.Result(), err
+		}
+		m, err := pcm.ReadFull(testReader, t[:n])
+		a.Add(r[:m], t[:m])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return a.Result(), err
+		}
+		if m < chunk {
+			return a.Result(), nil
+		}
+	}
+}
+
+// RoundTrip encodes an LPCM stream to A-law or u-law, decodes it back
+// and returns the metrics between the original and the decoded stream.
+func RoundTrip(lpcm io.Reader, law int) (Result, error) {
+	if law != g711.Alaw && law != g711.Ulaw {
+		return Result{}, errors.New("invalid input format")
+	}
+	reader, err := pcm.NewReader(lpcm, g711.Lpcm)
+	if err != nil {
+		return Result{}, err
+	}
+	a, _ := NewAccumulator(SegmentSize)
+	r := make([]int16, chunk)
+	var t []int16
+	var encoded []byte
+	for {
+		n, err := reader.Read(r)
+		encoded = pcm.Encode(law, r[:n], encoded[:0])
+		t = pcm.Decode(law, encoded, t[:0])
+		a.Add(r[:n], t)
+		if err == io.EOF {
+			return a.Result(), nil
+		}
+		if err != nil {
+			return a.Result(), err
+		}
+	}
+}
+
+// snr returns the ratio of signal to noise energy in dB
+func snr(signal, noise float64) float64 {
+	if noise == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(signal/noise)
+}
+
+// segmentSNR returns the SNR of a segment bounded to SegmentMin and SegmentMax
+func segmentSNR(signal, noise float64) float64 {
+	if noise == 0 {
+		return SegmentMax
+	}
+	return math.Max(SegmentMin, math.Min(SegmentMax, snr(signal, noise)))
+}
@@ -0,0 +1,79 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package metrics
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+)
+
+// Test the metrics of the G.711 speech samples against the original
+func TestCompare(t *testing.T) {
+	for _, tc := range []struct {
+		file   string
+		format int
+	}{
+		{"../testing/speech.alaw", g711.Alaw},
+		{"../testing/speech.ulaw", g711.Ulaw},
+	} {
+		ref, err := os.Open("../testing/speech.raw")
+		if err != nil {
+			t.Fatalf("Failed to open test data: %s\n", err)
+		}
+		test, err := os.Open(tc.file)
+		if err != nil {
+			t.Fatalf("Failed to open test data: %s\n", err)
+		}
+		r, err := Compare(ref, g711.Lpcm, test, tc.format)
+		ref.Close()
+		test.Close()
+		if err != nil {
+			t.Fatalf("Compare failed: %s\n", err)
+		}
+		if r.Samples != 20352 {
+			t.Errorf("%s: expected: 20352 samples, actual: %d", tc.file, r.Samples)
+		}
+		if r.SNR < 36 || r.SNR > 40 {
+			t.Errorf("%s: SNR out of range: %.2f dB", tc.file, r.SNR)
+		}
+	}
+}
+
+// Test the round trip metrics against Compare
+func TestRoundTrip(t *testing.T) {
+	raw, err := os.Open("../testing/speech.raw")
+	if err != nil {
+		t.Fatalf("Failed to open test data: %s\n", err)
+	}
+	defer raw.Close()
+	r, err := RoundTrip(raw, g711.Alaw)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %s\n", err)
+	}
+	if r.SNR < 36 || r.SNR > 40 {
+		t.Errorf("SNR out of range: %.2f dB", r.SNR)
+	}
+}
+
+// Test the Accumulator with known signals
+func TestAccumulator(t *testing.T) {
+	a, _ := NewAccumulator(2)
+	a.Add([]int16{100, -100, 100, -100}, []int16{100, -100, 100, -100})
+	if r := a.Result(); !math.IsInf(r.SNR, 1) || r.SegSNR != SegmentMax || r.MSE != 0 {
+		t.Errorf("Identical signals: %+v", r)
+	}
+	a.Reset()
+	a.Add([]int16{100, -100, 100, -100}, []int16{110, -110, 90, -90})
+	if r := a.Result(); r.SNR != 20 || r.MSE != 100 || r.MaxAbsError != 10 {
+		t.Errorf("Expected: SNR 20 dB, MSE 100, max error 10, actual: %+v", r)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package mf generates and detects in-band multi-frequency inter-register signals
+in G711 or LPCM audio: R1 MF (ITU-T Q.320 - Q.322), R2 MFC forward and backward
+signals (ITU-T Q.440 - Q.442) and 2600Hz single frequency (SF) line signalling.
+
+R1 signals are the digits 0-9, KP as '*', ST as '#' and ST', ST'' and ST''' as
+'A', 'B' and 'C'. R2 signals are numbered 1 to 15.
+*/
+package mf
+
+import (
+	"errors"
+
+	"github.com/zaf/g711/generator"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	blockSize = 80 // Samples per Goertzel block, 10ms
+	ms        = dsp.SampleRate / 1000
+	// R1 timing and levels, Q.320 - Q.322
+	r1Level     = -7  // Sending level per frequency in dBm0
+	r1KP        = 100 // KP duration in ms
+	r1Tone      = 68  // Digit duration in ms
+	r1Pause     = 68  // Pause between signals in ms
+	r1MinLevel  = -25 // Lowest level per frequency in dBm0
+	r1MinTone   = 30  // Shortest signal in ms
+	r1MinKP     = 55  // Shortest KP signal in ms
+	r1MinPause  = 20  // Shortest pause between signals in ms
+	r1Twist     = 6   // Largest level difference between the frequencies in dB
+	r2Level     = -8  // Sending level per frequency in dBm0
+	r2MinLevel  = -35 // Lowest level per frequency in dBm0
+	r2MinTone   = 30  // Shortest signal in ms
+	r2MinPause  = 20  // Shortest pause between signals in ms
+	r2Twist     = 7   // Largest level difference between the frequencies in dB
+	peakRatio   = 8   // Least excess of the two frequencies over the rest in dB
+	toneEnergy  = 0.7 // Least fraction of the block energy in the two frequencies
+	r1Signals   = "1234567890*#ABC"
+	maxR2Signal = 15
+)
+
+var (
+	r1Freqs    = [6]float64{700, 900, 1100, 1300, 1500, 1700}
+	r2Forward  = [6]float64{1380, 1500, 1620, 1740, 1860, 1980}
+	r2Backward = [6]float64{1140, 1020, 900, 780, 660, 540}
+	// Frequency pairs of the R1 signals in the order of r1Signals
+	r1Pairs = [][2]int{
+		{0, 1}, {0, 2}, {1, 2}, {0, 3}, {1, 3}, {2, 3}, {0, 4}, {1, 4}, {2, 4}, {3, 4},
+		{2, 5}, {4, 5}, {1, 5}, {3, 5}, {0, 5},
+	}
+	// Frequency pairs of the R2 signals 1 to 15
+	r2Pairs = [][2]int{
+		{0, 1}, {0, 2}, {1, 2}, {0, 3}, {1, 3}, {2, 3}, {0, 4}, {1, 4}, {2, 4}, {3, 4},
+		{0, 5}, {1, 5}, {2, 5}, {3, 5}, {4, 5},
+	}
+)
+
+// Event is a detected signal
+type Event struct {
+	Signal byte  // R1 signal character, R2 signal number or SF
+	Start  int64 // Sample offset of the start of the signal
+	End    int64 // Sample offset of the end of the signal
+}
+
+// R1 returns the R1 MF signals of a string, each at the Q.320 level and timing
+func R1(signals string) (generator.Signal, error) {
+	var s []generator.Signal
+	for i := 0; i < len(signals); i++ {
+		p, ok := pair(r1Signals, signals[i])
+		if !ok {
+			return nil, errors.New("invalid R1 signal")
+		}
+		d := r1Tone
+		if signals[i] == '*' {
+			d = r1KP
+		}
+		s = append(s, generator.DualTone(r1Freqs[r1Pairs[p][0]], r1Freqs[r1Pairs[p][1]], r1Level, d), generator.Silence(r1Pause))
+	}
+	return generator.Sequence(s...), nil
+}
+
+// R2Forward returns an R2 forward signal numbered 1 to 15 lasting ms milliseconds.
+// A duration of 0 or less generates an endless signal, as compelled signals last
+// until they are acknowledged.
+func R2Forward(signal int, ms int) (generator.Signal, error) {
+	return r2(r2Forward, signal, ms)
+}
+
+// R2Backward returns an R2 backward signal numbered 1 to 15 lasting ms milliseconds.
+// A duration of 0 or less generates an endless signal, as compelled signals last
+// until they are acknowledged.
+func R2Backward(signal int, ms int) (generator.Signal, error) {
+	return r2(r2Backward, signal, ms)
+}
+
+func r2(freqs [6]float64, signal int, ms int) (generator.Signal, error) {
+	if signal < 1 || signal > maxR2Signal {
+		return nil, errors.New("invalid R2 signal")
+	}
+	p := r2Pairs[signal-1]
+	return generator.DualTone(freqs[p[0]], freqs[p[1]], r2Level, ms), nil
+}
+
+// pair returns the index of a signal character in a signal set
+func pair(set string, c byte) (int, bool) {
+	for i := 0; i < len(set); i++ {
+		if set[i] == c {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Detector detects two-out-of-six multi-frequency signals in a stream of audio frames
+type Detector struct {
+	format     int // input format
+	goertzel   []dsp.Goertzel
+	signals    map[[2]int]byte // signal of each frequency pair
+	classify   func(s []float64) byte
+	minPower   float64 // Goertzel power at the lowest level
+	twist      float64 // largest power ratio between the frequencies
+	toneBlocks int     // blocks of a signal to detect it
+	kpBlocks   int     // blocks of a KP signal to detect it
+	framer     dsp.Framer
+	tracker    dsp.ToneTracker
+	samples    []int16 // decoding buffer
+	events     []Event
+}
+
+// NewR1Detector returns a pointer to a Detector of R1 MF signals for data in the given format
+func NewR1Detector(format int) (*Detector, error) {
+	signals := make(map[[2]int]byte)
+	for i, p := range r1Pairs {
+		signals[p] = r1Signals[i]
+	}
+	d, err := newDetector(format, r1Freqs, signals, r1MinLevel, r1Twist, r1MinTone, r1MinPause)
+	if err != nil {
+		return nil, err
+	}
+	d.kpBlocks = dsp.CompleteBlocks(r1MinKP, blockSize)
+	return d, nil
+}
+
+// NewR2Detector returns a pointer to a Detector of R2 forward or backward signals
+// for data in the given format
+func NewR2Detector(format int, backward bool) (*Detector, error) {
+	signals := make(map[[2]int]byte)
+	for i, p := range r2Pairs {
+		signals[p] = byte(i + 1)
+	}
+	freqs := r2Forward
+	if backward {
+		freqs = r2Backward
+	}
+	return newDetector(format, freqs, signals, r2MinLevel, r2Twist, r2MinTone, r2MinPause)
+}
+
+func newDetector(format int, freqs [6]float64, signals map[[2]int]byte, level, twist float64, tone, pause int) (*Detector, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	p := dsp.Amplitude(level) * blockSize / 2
+	d := &Detector{
+		format:     format,
+		signals:    signals,
+		minPower:   p * p,
+		twist:      dsp.DB(twist),
+		toneBlocks: dsp.CompleteBlocks(tone, blockSize),
+		framer:     dsp.Framer{Size: blockSize},
+		tracker:    dsp.ToneTracker{Size: blockSize, Gap: dsp.CompleteBlocks(pause, blockSize)},
+	}
+	d.kpBlocks = d.toneBlocks
+	d.classify = d.pair
+	for _, f := range freqs {
+		d.goertzel = append(d.goertzel, dsp.NewGoertzel(f))
+	}
+	return d, nil
+}
+
+// Process detects signals in a frame of audio data and returns the
+// signals that ended within it.
+func (d *Detector) Process(frame []byte) []Event {
+	d.samples = pcm.Decode(d.format, frame, d.samples[:0])
+	return d.ProcessSamples(d.samples)
+}
+
+// ProcessSamples detects signals in 16bit linear samples and returns the
+// signals that ended within them.
+func (d *Detector) ProcessSamples(s []int16) []Event {
+	d.events = d.events[:0]
+	d.framer.Push(s, d.block)
+	return d.events
+}
+
+// Flush ends the stream and returns any signal still active
+func (d *Detector) Flush() []Event {
+	d.events = d.events[:0]
+	if t, ok := d.tracker.Flush(); ok {
+		d.emit(t)
+	}
+	return d.events
+}
+
+// Active returns the signal present on the line or 0 if there is none.
+// It permits acknowledging compelled R2 signals while they last.
+func (d *Detector) Active() byte {
+	return d.tracker.Active()
+}
+
+// Reset discards the Detector state. This permits reusing a Detector rather than allocating a new one.
+func (d *Detector) Reset() {
+	d.framer.Reset()
+	d.tracker.Reset()
+}
+
+// block runs the detection on a single block
+func (d *Detector) block(s []float64) {
+	c := d.classify(s)
+	need := d.toneBlocks
+	if c == '*' {
+		need = d.kpBlocks
+	}
+	if t, ok := d.tracker.Push(c, need); ok {
+		d.emit(t)
+	}
+}
+
+// emit reports an ended signal
+func (d *Detector) emit(t dsp.ToneRun) {
+	d.events = append(d.events, Event{Signal: t.Class, Start: t.Start, End: t.End})
+}
+
+// pair returns the two-out-of-six signal present in a block or 0
+func (d *Detector) pair(s []float64) byte {
+	var power [6]float64
+	first, second := -1, -1
+	for i, g := range d.goertzel {
+		power[i] = g.Power(s)
+		if first < 0 || power[i] > power[first] {
+			first, second = i, first
+		} else if second < 0 || power[i] > power[second] {
+			second = i
+		}
+	}
+	p1, p2 := power[first], power[second]
+	if p2 < d.minPower || p1 > p2*d.twist {
+		return 0
+	}
+	peak := dsp.DB(peakRatio)
+	for i, p := range power {
+		if i != first && i != second && p*peak > p2 {
+			return 0
+		}
+	}
+	if dsp.ToneEnergy(p1+p2, len(s)) < toneEnergy*dsp.Energy(s) {
+		return 0
+	}
+	if first > second {
+		first, second = second, first
+	}
+	return d.signals[[2]int{first, second}]
+}
@@ -0,0 +1,195 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package mf
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/generator"
+)
+
+// render returns a signal encoded in the given format
+func render(t *testing.T, s generator.Signal, format int) []byte {
+	r, err := generator.NewReader(s, format)
+	if err != nil {
+		t.Fatalf("Failed to create Reader: %s\n", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read signal: %s\n", err)
+	}
+	return data
+}
+
+// detect runs a Detector over data in chunks of 160 bytes
+func detect(d *Detector, data []byte) []Event {
+	var events []Event
+	for len(data) > 0 {
+		n := 160
+		if n > len(data) {
+			n = len(data)
+		}
+		events = append(events, d.Process(data[:n])...)
+		data = data[n:]
+	}
+	return append(events, d.Flush()...)
+}
+
+// Test R1 signals in all formats
+func TestR1(t *testing.T) {
+	const signals = "*0123456789#ABC"
+	for _, format := range []int{g711.Lpcm, g711.Alaw, g711.Ulaw} {
+		s, err := R1(signals)
+		if err != nil {
+			t.Fatalf("Failed to generate R1 signals: %s\n", err)
+		}
+		d, err := NewR1Detector(format)
+		if err != nil {
+			t.Fatalf("Failed to create Detector: %s\n", err)
+		}
+		var got string
+		for _, e := range detect(d, render(t, s, format)) {
+			got += string(e.Signal)
+			want := int64(r1Tone * ms)
+			if e.Signal == '*' {
+				want = r1KP * ms
+			}
+			if l := e.End - e.Start; l < want-2*blockSize || l > want+2*blockSize {
+				t.Errorf("Format %d: signal %c lasts %d samples", format, e.Signal, l)
+			}
+		}
+		if got != signals {
+			t.Errorf("Format %d: expected: %s, actual: %s", format, signals, got)
+		}
+	}
+	if _, err := R1("12X"); err == nil {
+		t.Error("Invalid R1 signal accepted")
+	}
+}
+
+// Test R2 forward and backward signals
+func TestR2(t *testing.T) {
+	for _, backward := range []bool{false, true} {
+		for _, format := range []int{g711.Alaw, g711.Ulaw} {
+			var signals []generator.Signal
+			for n := 1; n <= maxR2Signal; n++ {
+				gen := R2Forward
+				if backward {
+					gen = R2Backward
+				}
+				s, err := gen(n, 150)
+				if err != nil {
+					t.Fatalf("Failed to generate R2 signal: %s\n", err)
+				}
+				signals = append(signals, s, generator.Silence(50))
+			}
+			d, err := NewR2Detector(format, backward)
+			if err != nil {
+				t.Fatalf("Failed to create Detector: %s\n", err)
+			}
+			data := render(t, generator.Sequence(signals...), format)
+			events := detect(d, data)
+			if len(events) != maxR2Signal {
+				t.Fatalf("Backward %t format %d: expected %d signals, actual: %v", backward, format, maxR2Signal, events)
+			}
+			for i, e := range events {
+				if int(e.Signal) != i+1 {
+					t.Errorf("Backward %t format %d: expected signal %d, actual: %d", backward, format, i+1, e.Signal)
+				}
+			}
+			// Forward signals must not trigger a backward detector and vice versa
+			other, _ := NewR2Detector(format, !backward)
+			if events := detect(other, data); len(events) != 0 {
+				t.Errorf("Backward %t format %d: signals of the wrong direction detected: %v", backward, format, events)
+			}
+		}
+	}
+	if _, err := R2Forward(16, 100); err == nil {
+		t.Error("Invalid R2 signal accepted")
+	}
+}
+
+// Test the Q.322 timing and level limits
+func TestLimits(t *testing.T) {
+	var tests = []struct {
+		name   string
+		signal generator.Signal
+		detect bool
+	}{
+		{"nominal", generator.DualTone(900, 1300, r1Level, 68), true},
+		{"30ms", generator.DualTone(900, 1300, r1Level, 30), true},
+		{"10ms", generator.DualTone(900, 1300, r1Level, 10), false},
+		{"KP 55ms", generator.DualTone(1100, 1700, r1Level, 55), true},
+		{"KP 30ms", generator.DualTone(1100, 1700, r1Level, 30), false},
+		{"-22dBm0", generator.DualTone(700, 1500, -22, 68), true},
+		{"-35dBm0", generator.DualTone(700, 1500, -35, 68), false},
+		{"single frequency", generator.Sine(1100, r1Level, 68), false},
+		{"three frequencies", generator.Tone([]float64{700, 900, 1100}, r1Level, 68), false},
+		{"1.5% + 5Hz", generator.DualTone(900*1.015+5, 1300*0.985-5, r1Level, 68), true},
+	}
+	for _, tc := range tests {
+		d, _ := NewR1Detector(g711.Alaw)
+		events := detect(d, render(t, tc.signal, g711.Alaw))
+		if (len(events) == 1) != tc.detect {
+			t.Errorf("%s: expected detection: %t, actual: %v", tc.name, tc.detect, events)
+		}
+	}
+}
+
+// Test the 2600Hz tone with a seizure and dial pulses
+func TestSF(t *testing.T) {
+	for _, format := range []int{g711.Lpcm, g711.Alaw, g711.Ulaw} {
+		signal := []generator.Signal{SFTone(500)}
+		// Two dial pulses: 60ms break, 40ms make
+		for i := 0; i < 2; i++ {
+			signal = append(signal, generator.Silence(60), SFTone(40))
+		}
+		signal = append(signal, generator.Silence(300), SFTone(200))
+		d, err := NewSFDetector(format)
+		if err != nil {
+			t.Fatalf("Failed to create Detector: %s\n", err)
+		}
+		events := detect(d, render(t, generator.Sequence(signal...), format))
+		want := []int64{500, 40, 40, 200}
+		if len(events) != len(want) {
+			t.Fatalf("Format %d: expected %d tones, actual: %v", format, len(want), events)
+		}
+		for i, e := range events {
+			if e.Signal != SF {
+				t.Errorf("Format %d: unexpected signal %c", format, e.Signal)
+			}
+			if l := e.End - e.Start; l < want[i]*ms-2*blockSize || l > want[i]*ms+2*blockSize {
+				t.Errorf("Format %d: tone %d lasts %d samples, expected %d", format, i, l, want[i]*ms)
+			}
+		}
+	}
+	if _, err := NewSFDetector(5); err == nil {
+		t.Error("Invalid format accepted")
+	}
+}
+
+// Test that speech does not trigger the detectors
+func TestSpeech(t *testing.T) {
+	data, err := os.ReadFile("../testing/speech.alaw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	r1, _ := NewR1Detector(g711.Alaw)
+	forward, _ := NewR2Detector(g711.Alaw, false)
+	backward, _ := NewR2Detector(g711.Alaw, true)
+	sf, _ := NewSFDetector(g711.Alaw)
+	for _, d := range []*Detector{r1, forward, backward, sf} {
+		if events := detect(d, data); len(events) != 0 {
+			t.Errorf("False detection: %v", events)
+		}
+	}
+}
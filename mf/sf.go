@@ -0,0 +1,65 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package mf
+
+import (
+	"errors"
+
+	"github.com/zaf/g711/generator"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	sfFreq     = 2600
+	sfLevel    = -20 // Idle sending level in dBm0
+	sfMinLevel = -33 // Lowest level in dBm0
+	sfMinTone  = 30  // Shortest tone in ms, dial pulse breaks last 60ms
+	sfMinGap   = 20  // Shortest interruption of the tone in ms
+	sfEnergy   = 0.8 // Least fraction of the block energy in the tone, guards against talk-off
+)
+
+// SF is the Signal of the events reported for the 2600Hz tone
+const SF byte = 'F'
+
+// SFTone returns the 2600Hz single frequency idle tone lasting ms milliseconds.
+// A duration of 0 or less generates an endless tone.
+func SFTone(ms int) generator.Signal {
+	return generator.Sine(sfFreq, sfLevel, ms)
+}
+
+// NewSFDetector returns a pointer to a Detector of the 2600Hz single frequency
+// tone for data in the given format. The tone marks an idle trunk, its removal a
+// seizure and its short interruptions dial pulses.
+func NewSFDetector(format int) (*Detector, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	p := dsp.Amplitude(sfMinLevel) * blockSize / 2
+	d := &Detector{
+		format:     format,
+		goertzel:   []dsp.Goertzel{dsp.NewGoertzel(sfFreq)},
+		minPower:   p * p,
+		toneBlocks: dsp.CompleteBlocks(sfMinTone, blockSize),
+		framer:     dsp.Framer{Size: blockSize},
+		tracker:    dsp.ToneTracker{Size: blockSize, Gap: dsp.CompleteBlocks(sfMinGap, blockSize)},
+	}
+	d.kpBlocks = d.toneBlocks
+	d.classify = d.single
+	return d, nil
+}
+
+// single returns SF if the tone dominates a block or 0
+func (d *Detector) single(s []float64) byte {
+	p := d.goertzel[0].Power(s)
+	if p < d.minPower || dsp.ToneEnergy(p, len(s)) < sfEnergy*dsp.Energy(s) {
+		return 0
+	}
+	return SF
+}
@@ -0,0 +1,201 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package milliwatt generates and validates the G711 digital milliwatt, the periodic
+8 byte sequence of ITU-T G.711 Tables 5 and 6 that decodes to a 1kHz sine wave
+at 0dBm0. The Validator checks a received A-law or u-law stream for bit exact
+milliwatt content, the usual end to end test of a digital voice path.
+*/
+package milliwatt
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/dsp"
+)
+
+// Period is the length of the milliwatt sequence in bytes
+const Period = 8
+
+var (
+	alaw = [Period]byte{0x34, 0x21, 0x21, 0x34, 0xb4, 0xa1, 0xa1, 0xb4}
+	ulaw = [Period]byte{0x1e, 0x0b, 0x0b, 0x1e, 0x9e, 0x8b, 0x8b, 0x9e}
+)
+
+// Sequence returns the digital milliwatt sequence of an A-law or u-law stream
+func Sequence(law int) ([Period]byte, error) {
+	switch law {
+	case g711.Alaw:
+		return alaw, nil
+	case g711.Ulaw:
+		return ulaw, nil
+	}
+	return [Period]byte{}, errors.New("invalid format")
+}
+
+// Reader is an endless source of the digital milliwatt
+type Reader struct {
+	seq   [Period]byte
+	phase int // position of the next byte in the sequence
+}
+
+// NewReader returns a pointer to a Reader of the A-law or u-law digital milliwatt.
+// Wrap it in an io.LimitReader to get a finite stream, 8 bytes per millisecond.
+func NewReader(law int) (*Reader, error) {
+	seq, err := Sequence(law)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{seq: seq}, nil
+}
+
+// Read fills p with the milliwatt sequence, continuing from the previous call.
+// It always returns len(p) and a nil error.
+func (r *Reader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.seq[r.phase]
+		r.phase = (r.phase + 1) % Period
+	}
+	return len(p), nil
+}
+
+// Report is the outcome of a validation
+type Report struct {
+	Bytes     int64   // Bytes checked after the sequence was acquired
+	Errors    int64   // Bytes that differ from the sequence
+	BitErrors int64   // Bits that differ from the sequence
+	Slips     int     // Changes of the sequence phase, a lost or repeated byte each
+	Level     float64 // Level of all received bytes in dBm0
+	Offset    float64 // Level difference from the exact sequence in dB
+}
+
+// Locked reports whether the milliwatt sequence was found in the stream
+func (r Report) Locked() bool {
+	return r.Bytes > 0
+}
+
+// Validator checks a stream for the digital milliwatt. It is an io.Writer.
+type Validator struct {
+	seq     [Period]byte
+	decode  func(frame uint8) int16
+	nominal float64       // power of the exact sequence
+	window  [Period]byte  // last received bytes
+	errs    [Period]int64 // bit errors of the last received bytes
+	n       int64         // bytes received
+	locked  bool
+	phase   int     // expected position of the next byte in the sequence
+	power   float64 // sum of squares of the received bytes
+	report  Report
+}
+
+// NewValidator returns a pointer to a Validator of an A-law or u-law stream
+func NewValidator(law int) (*Validator, error) {
+	seq, err := Sequence(law)
+	if err != nil {
+		return nil, err
+	}
+	v := &Validator{seq: seq, decode: g711.DecodeAlawFrame}
+	if law == g711.Ulaw {
+		v.decode = g711.DecodeUlawFrame
+	}
+	for _, b := range seq {
+		x := float64(v.decode(b))
+		v.nominal += x * x / Period
+	}
+	return v, nil
+}
+
+// Write checks the bytes of p against the sequence. It always returns len(p) and a nil error.
+func (v *Validator) Write(p []byte) (int, error) {
+	for _, b := range p {
+		v.check(b)
+	}
+	return len(p), nil
+}
+
+// check validates one received byte
+func (v *Validator) check(b byte) {
+	i := int(v.n % Period)
+	v.window[i] = b
+	v.n++
+	x := float64(v.decode(b))
+	v.power += x * x
+	if v.locked {
+		e := int64(bits.OnesCount8(b ^ v.seq[v.phase]))
+		v.errs[i] = e
+		v.count(e)
+		v.phase = (v.phase + 1) % Period
+		if e == 0 {
+			return
+		}
+	}
+	if v.n < Period {
+		return
+	}
+	// Look for the sequence at another phase in the last Period bytes
+	for q := 0; q < Period; q++ {
+		if q == v.phase && v.locked {
+			continue
+		}
+		match := true
+		for k := 0; k < Period && match; k++ {
+			match = v.window[(int(v.n)+k)%Period] == v.seq[(q+k)%Period]
+		}
+		if !match {
+			continue
+		}
+		if v.locked {
+			// The errors of the window were caused by the slip
+			v.report.Slips++
+			for k, e := range v.errs {
+				if e > 0 {
+					v.report.Errors--
+					v.report.BitErrors -= e
+					v.errs[k] = 0
+				}
+			}
+		} else {
+			// The bytes of the window are the first checked
+			v.locked = true
+			v.report.Bytes += Period
+		}
+		v.phase = q
+		return
+	}
+}
+
+// count adds a checked byte to the report
+func (v *Validator) count(bitErrors int64) {
+	v.report.Bytes++
+	if bitErrors > 0 {
+		v.report.Errors++
+		v.report.BitErrors += bitErrors
+	}
+}
+
+// Report returns the validation results so far
+func (v *Validator) Report() Report {
+	r := v.report
+	if v.n > 0 {
+		p := v.power / float64(v.n)
+		r.Level = dsp.Level(p)
+		r.Offset = 10 * math.Log10(p/v.nominal)
+	}
+	return r
+}
+
+// Reset discards the Validator state. This permits reusing a Validator rather than allocating a new one.
+func (v *Validator) Reset() {
+	v.n, v.locked, v.phase, v.power = 0, false, 0, 0
+	v.errs = [Period]int64{}
+	v.report = Report{}
+}
@@ -0,0 +1,79 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package milliwatt
+
+import (
+	"io"
+	"math"
+	"testing"
+
+	"github.com/zaf/g711"
+)
+
+// stream returns n bytes of the milliwatt
+func stream(t *testing.T, law, n int) []byte {
+	r, err := NewReader(law)
+	if err != nil {
+		t.Fatalf("Failed to create Reader: %s\n", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		t.Fatalf("Failed to read milliwatt: %s\n", err)
+	}
+	return data
+}
+
+// validate returns the Report of a Validator over data
+func validate(t *testing.T, law int, data []byte) Report {
+	v, err := NewValidator(law)
+	if err != nil {
+		t.Fatalf("Failed to create Validator: %s\n", err)
+	}
+	v.Write(data)
+	return v.Report()
+}
+
+// Test the validation of clean and impaired streams
+func TestValidator(t *testing.T) {
+	for _, law := range []int{g711.Alaw, g711.Ulaw} {
+		clean := stream(t, law, 8000)
+		r := validate(t, law, clean)
+		if r.Bytes != 8000 || r.Errors != 0 || r.Slips != 0 || r.Offset != 0 {
+			t.Errorf("Law %d clean: %+v", law, r)
+		}
+		if math.Abs(r.Level) > 0.2 {
+			t.Errorf("Law %d: milliwatt level %.2f dBm0", law, r.Level)
+		}
+		// A lost byte, a repeated byte and a bit error
+		data := append([]byte{}, clean[:1003]...)
+		data = append(data, clean[1004:5000]...)
+		data = append(data, clean[4999:]...)
+		data[6000] ^= 0x10
+		r = validate(t, law, data)
+		if r.Slips != 2 || r.Errors != 1 || r.BitErrors != 1 {
+			t.Errorf("Law %d impaired: %+v", law, r)
+		}
+		// Garbage before the sequence is not checked
+		data = append([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, clean[3:1003]...)
+		if r = validate(t, law, data); r.Bytes != 1000 || r.Errors != 0 {
+			t.Errorf("Law %d acquisition: %+v", law, r)
+		}
+		// A 6dB pad
+		pad, _ := g711.Gain(law, -6)
+		data = append([]byte{}, clean...)
+		pad.ApplyGain(data)
+		r = validate(t, law, data)
+		if r.Locked() || math.Abs(r.Offset+6) > 0.5 {
+			t.Errorf("Law %d padded: %+v", law, r)
+		}
+	}
+	if _, err := NewValidator(g711.Lpcm); err == nil {
+		t.Error("LPCM accepted")
+	}
+}
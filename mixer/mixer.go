@@ -0,0 +1,263 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package mixer sums multiple G711 or LPCM streams into a single stream.
+
+Inputs can be mixed A-law, u-law and LPCM and the output can be in any of
+those formats. Besides the full mix, the mixer can produce N-minus-one mixes
+where every participant receives the mix of all others but not its own voice.
+*/
+package mixer
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// Limiter is the method used to keep the mix within the 16bit range
+type Limiter int
+
+const (
+	// Limiters
+	HardClip  Limiter = iota // Clip samples at the 16bit limits
+	SoftLimit                // Compress samples above the threshold smoothly towards the 16bit limits
+)
+
+const (
+	threshold = 16384 // Soft limiter threshold, -6dBFS
+	headroom  = 32767 - threshold
+)
+
+// Frame is a block of audio data of a mixer participant
+type Frame struct {
+	Data   []byte // Audio data
+	Format int    // Data format, g711.Alaw, g711.Ulaw or g711.Lpcm
+}
+
+// Mixer mixes G711 and LPCM audio
+type Mixer struct {
+	output  int           // output format
+	limiter Limiter       // limiting method
+	sources []*pcm.Reader // stream participants
+	done    []bool        // streams that reached EOF
+	samples [][]int16     // decoded samples per participant
+	sum     []int32       // sum of all participants
+}
+
+// New returns a pointer to a Mixer that produces data in the output format
+// and keeps the mix in range with the given limiter.
+func New(output int, limiter Limiter) (*Mixer, error) {
+	if !pcm.Valid(output) {
+		return nil, errors.New("invalid output format")
+	}
+	if limiter != HardClip && limiter != SoftLimit {
+		return nil, errors.New("invalid limiter")
+	}
+	return &Mixer{output: output, limiter: limiter}, nil
+}
+
+// Mix sums frames of audio data and returns the mix in the output format.
+// Frames shorter than the longest one are padded with silence.
+func (m *Mixer) Mix(frames []Frame) ([]byte, error) {
+	if err := m.decode(frames); err != nil {
+		return nil, err
+	}
+	return pcm.Encode(m.output, m.limit(m.sum, nil), nil), nil
+}
+
+// MixMinusOne returns for every frame the mix of all other frames in the output format
+func (m *Mixer) MixMinusOne(frames []Frame) ([][]byte, error) {
+	if err := m.decode(frames); err != nil {
+		return nil, err
+	}
+	return m.minusOne(), nil
+}
+
+// AddReader adds a participant stream of the given format to the Mixer
+func (m *Mixer) AddReader(reader io.Reader, format int) error {
+	r, err := pcm.NewReader(reader, format)
+	if err != nil {
+		return err
+	}
+	m.sources = append(m.sources, r)
+	m.done = append(m.done, false)
+	return nil
+}
+
+// Reset discards the participant streams. This permits reusing a Mixer rather than allocating a new one.
+func (m *Mixer) Reset() {
+	m.sources = nil
+	m.done = nil
+}
+
+// Read reads the mix of the participant streams in the output format. Reads up to len(p) bytes
+// into p, returns the number of bytes read and any error encountered. Streams that end early
+// are mixed as silence and io.EOF is returned once all streams have ended.
+func (m *Mixer) Read(p []byte) (int, error) {
+	n, err := m.read(len(p) / pcm.Size(m.output))
+	if n == 0 {
+		return 0, err
+	}
+	return len(pcm.Encode(m.output, m.limit(m.sum[:n], nil), p[:0])), err
+}
+
+// ReadMinusOne reads the N-minus-one mixes of the participant streams in the output format.
+// Reads up to len(p[i]) bytes into p[i] for every participant i, in the order they were added,
+// returns the number of bytes read into each buffer and any error encountered.
+func (m *Mixer) ReadMinusOne(p [][]byte) (int, error) {
+	if len(p) != len(m.sources) {
+		return 0, errors.New("number of buffers does not match the number of participants")
+	}
+	size := -1
+	for _, b := range p {
+		if size < 0 || len(b) < size {
+			size = len(b)
+		}
+	}
+	n, err := m.read(size / pcm.Size(m.output))
+	if n == 0 {
+		return 0, err
+	}
+	for i, b := range m.minusOne() {
+		copy(p[i], b)
+	}
+	return n * pcm.Size(m.output), err
+}
+
+// read reads up to n samples from every participant stream and sums them
+func (m *Mixer) read(n int) (int, error) {
+	if len(m.sources) == 0 {
+		return 0, errors.New("no participants")
+	}
+	m.resize(len(m.sources))
+	longest := 0
+	ended := true
+	for i, r := range m.sources {
+		m.samples[i] = m.samples[i][:0]
+		if m.done[i] {
+			continue
+		}
+		if cap(m.samples[i]) < n {
+			m.samples[i] = make([]int16, n)
+		}
+		k, err := pcm.ReadFull(r, m.samples[i][:n])
+		m.samples[i] = m.samples[i][:k]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			m.done[i] = true
+		} else if err != nil {
+			return 0, err
+		} else {
+			ended = false
+		}
+		if k > longest {
+			longest = k
+		}
+	}
+	m.sumSamples(longest)
+	if ended {
+		return longest, io.EOF
+	}
+	return longest, nil
+}
+
+// decode decodes frames to samples and sums them
+func (m *Mixer) decode(frames []Frame) error {
+	m.resize(len(frames))
+	longest := 0
+	for i, f := range frames {
+		if !pcm.Valid(f.Format) {
+			return errors.New("invalid input format")
+		}
+		m.samples[i] = pcm.Decode(f.Format, f.Data, m.samples[i][:0])
+		if len(m.samples[i]) > longest {
+			longest = len(m.samples[i])
+		}
+	}
+	m.sumSamples(longest)
+	return nil
+}
+
+// resize sets the number of participant sample buffers
+func (m *Mixer) resize(n int) {
+	for len(m.samples) < n {
+		m.samples = append(m.samples, nil)
+	}
+	m.samples = m.samples[:n]
+}
+
+// sumSamples sums n samples of every participant, missing samples are silence
+func (m *Mixer) sumSamples(n int) {
+	if cap(m.sum) < n {
+		m.sum = make([]int32, n)
+	}
+	m.sum = m.sum[:n]
+	for i := range m.sum {
+		m.sum[i] = 0
+	}
+	for _, s := range m.samples {
+		for i, x := range s {
+			m.sum[i] += int32(x)
+		}
+	}
+}
+
+// minusOne returns the mix without each participant in the output format
+func (m *Mixer) minusOne() [][]byte {
+	mixes := make([][]byte, len(m.samples))
+	mix := make([]int32, len(m.sum))
+	var out []int16
+	for i, s := range m.samples {
+		copy(mix, m.sum)
+		for j, x := range s {
+			mix[j] -= int32(x)
+		}
+		out = m.limit(mix, out[:0])
+		mixes[i] = pcm.Encode(m.output, out, nil)
+	}
+	return mixes
+}
+
+// limit brings the summed samples into the 16bit range
+func (m *Mixer) limit(sum []int32, out []int16) []int16 {
+	for _, x := range sum {
+		if m.limiter == SoftLimit {
+			out = append(out, softLimit(x))
+		} else {
+			out = append(out, hardClip(x))
+		}
+	}
+	return out
+}
+
+func hardClip(x int32) int16 {
+	if x > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(x)
+}
+
+// softLimit passes samples below the threshold unchanged and compresses
+// the rest with a tanh curve that approaches full scale asymptotically
+func softLimit(x int32) int16 {
+	if x <= threshold && x >= -threshold {
+		return int16(x)
+	}
+	sign := 1.0
+	v := float64(x)
+	if v < 0 {
+		sign, v = -1, -v
+	}
+	return int16(sign * (threshold + headroom*math.Tanh((v-threshold)/headroom)))
+}
@@ -0,0 +1,125 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package mixer
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// Test mixing frames of different formats
+func TestMix(t *testing.T) {
+	m, _ := New(g711.Lpcm, HardClip)
+	frames := []Frame{
+		{pcm.Encode(g711.Alaw, []int16{1000, 30000, -100}, nil), g711.Alaw},
+		{pcm.Encode(g711.Ulaw, []int16{2000, 30000}, nil), g711.Ulaw},
+		{pcm.Encode(g711.Lpcm, []int16{-500, 0, 0, 700}, nil), g711.Lpcm},
+	}
+	mix, err := m.Mix(frames)
+	if err != nil {
+		t.Fatalf("Mix failed: %s\n", err)
+	}
+	s := pcm.Decode(g711.Lpcm, mix, nil)
+	want := []int16{
+		g711.DecodeAlawFrame(g711.EncodeAlawFrame(1000)) + g711.DecodeUlawFrame(g711.EncodeUlawFrame(2000)) - 500,
+		32767,
+		g711.DecodeAlawFrame(g711.EncodeAlawFrame(-100)),
+		700,
+	}
+	if len(s) != len(want) {
+		t.Fatalf("Expected: %d samples, actual: %d", len(want), len(s))
+	}
+	for i := range want {
+		if s[i] != want[i] {
+			t.Errorf("Sample %d: expected: %d, actual: %d", i, want[i], s[i])
+		}
+	}
+	mixes, err := m.MixMinusOne(frames)
+	if err != nil {
+		t.Fatalf("MixMinusOne failed: %s\n", err)
+	}
+	if s = pcm.Decode(g711.Lpcm, mixes[2], nil); s[3] != 0 || s[0] != want[0]+500 {
+		t.Errorf("N-minus-one mix contains the participant: %v", s)
+	}
+	if _, err = m.Mix([]Frame{{[]byte{0}, 5}}); err == nil {
+		t.Error("Mix accepted an invalid format")
+	}
+}
+
+// Test that a mix is as long as the frames of its call
+func TestMixLength(t *testing.T) {
+	m, _ := New(g711.Lpcm, HardClip)
+	for _, n := range []int{4, 2, 1} {
+		s := make([]int16, n)
+		for i := range s {
+			s[i] = int16(i + 5)
+		}
+		frames := []Frame{{pcm.Encode(g711.Lpcm, s, nil), g711.Lpcm}, {pcm.Encode(g711.Lpcm, s[:n-1], nil), g711.Lpcm}}
+		mix, err := m.Mix(frames)
+		if err != nil {
+			t.Fatalf("Mix failed: %s\n", err)
+		}
+		if out := pcm.Decode(g711.Lpcm, mix, nil); len(out) != n || out[n-1] != s[n-1] {
+			t.Errorf("Mix of %d samples: %v", n, out)
+		}
+		mixes, err := m.MixMinusOne(frames)
+		if err != nil {
+			t.Fatalf("MixMinusOne failed: %s\n", err)
+		}
+		for i, b := range mixes {
+			if len(b) != 2*n {
+				t.Errorf("N-minus-one mix %d of %d samples: %d bytes", i, n, len(b))
+			}
+		}
+	}
+}
+
+// Test the soft limiter
+func TestSoftLimit(t *testing.T) {
+	prev := int16(0)
+	for x := int32(0); x < 4*32768; x += 7 {
+		y := softLimit(x)
+		if y < prev {
+			t.Fatalf("Soft limiter is not monotonic at %d", x)
+		}
+		if y != softLimit(-x)*-1 {
+			t.Fatalf("Soft limiter is not symmetric at %d", x)
+		}
+		prev = y
+	}
+	if softLimit(1000) != 1000 {
+		t.Error("Soft limiter changed a sample below the threshold")
+	}
+}
+
+// Test mixing streams
+func TestRead(t *testing.T) {
+	m, _ := New(g711.Ulaw, SoftLimit)
+	m.AddReader(bytes.NewReader(pcm.Encode(g711.Alaw, make([]int16, 100), nil)), g711.Alaw)
+	m.AddReader(bytes.NewReader(pcm.Encode(g711.Lpcm, make([]int16, 300), nil)), g711.Lpcm)
+	mix, err := io.ReadAll(m)
+	if err != nil {
+		t.Fatalf("Read failed: %s\n", err)
+	}
+	if len(mix) != 300 {
+		t.Errorf("Expected: 300 bytes, actual: %d", len(mix))
+	}
+	m.Reset()
+	m.AddReader(bytes.NewReader([]byte{g711.EncodeAlawFrame(1000)}), g711.Alaw)
+	m.AddReader(bytes.NewReader([]byte{g711.EncodeAlawFrame(2000)}), g711.Alaw)
+	p := [][]byte{make([]byte, 4), make([]byte, 4)}
+	n, _ := m.ReadMinusOne(p)
+	if n != 1 || g711.DecodeUlawFrame(p[0][0]) < 1900 || g711.DecodeUlawFrame(p[1][0]) > 1100 {
+		t.Errorf("Unexpected N-minus-one mix: %d bytes, %v", n, p)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package p56 measures the active speech level of G711 or LPCM audio following
+ITU-T P.56 method B, and normalises audio to a target active level.
+
+Levels are in dBov, relative to the RMS of a full scale 16bit square wave.
+The usual target for speech is -26dBov.
+*/
+package p56
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	// Target is the usual active speech level in dBov
+	Target     = -26.0
+	timeConst  = 0.03 // Envelope smoothing time constant in seconds
+	hangover   = 0.2  // Activity hangover in seconds
+	margin     = 15.9 // Margin between the active level and the threshold in dB
+	thresholds = 15   // Activity thresholds, one per power of 2 of the 16bit range
+	fullScale  = 32768.0
+)
+
+// Result is the outcome of a measurement
+type Result struct {
+	Samples  int64   // Samples measured
+	Level    float64 // Active speech level in dBov
+	RMS      float64 // Long term level in dBov
+	Peak     float64 // Peak level in dBov
+	Activity float64 // Fraction of the samples with active speech
+}
+
+// Meter measures the active speech level of a stream of samples
+type Meter struct {
+	g        float64 // envelope smoothing coefficient
+	hold     int     // hangover in samples
+	p, q     float64 // envelope filter states
+	sq       float64 // sum of squares
+	peak     int     // largest absolute sample
+	n        int64   // samples measured
+	active   [thresholds]int64
+	hangover [thresholds]int
+}
+
+// NewMeter returns a pointer to a Meter
+func NewMeter() *Meter {
+	return &Meter{
+		g:    math.Exp(-1 / (dsp.SampleRate * timeConst)),
+		hold: int(math.Ceil(dsp.SampleRate * hangover)),
+	}
+}
+
+// Add measures 16bit linear samples
+func (m *Meter) Add(s []int16) {
+	for _, x := range s {
+		a := math.Abs(float64(x))
+		m.p = m.g*m.p + (1-m.g)*a
+		m.q = m.g*m.q + (1-m.g)*m.p
+		m.sq += a * a
+		if int(a) > m.peak {
+			m.peak = int(a)
+		}
+		m.n++
+		for j := range m.active {
+			switch {
+			case m.q >= threshold(j):
+				m.active[j]++
+				m.hangover[j] = 0
+			case m.hangover[j] < m.hold:
+				m.active[j]++
+				m.hangover[j]++
+			}
+		}
+	}
+}
+
+// threshold returns the envelope threshold j in 16bit sample units
+func threshold(j int) float64 {
+	return float64(int(1) << j)
+}
+
+// Result returns the measurement of the samples so far
+func (m *Meter) Result() Result {
+	r := Result{Samples: m.n, Level: math.Inf(-1), RMS: math.Inf(-1), Peak: math.Inf(-1)}
+	if m.n == 0 || m.sq == 0 {
+		return r
+	}
+	r.RMS = dbov(m.sq / float64(m.n))
+	r.Peak = 20 * math.Log10(float64(m.peak)/fullScale)
+	// The active level is where its distance from the threshold falls to the margin
+	prevLevel, prevDelta := 0.0, 0.0
+	for j := range m.active {
+		if m.active[j] == 0 {
+			break
+		}
+		level := dbov(m.sq / float64(m.active[j]))
+		delta := level - 20*math.Log10(threshold(j)/fullScale)
+		if delta <= margin {
+			if j > 0 {
+				f := (prevDelta - margin) / (prevDelta - delta)
+				level = prevLevel + f*(level-prevLevel)
+			}
+			r.Level = level
+			r.Activity = math.Min(1, math.Pow(10, (r.RMS-level)/10))
+			return r
+		}
+		prevLevel, prevDelta = level, delta
+	}
+	// Every threshold is below the margin: the signal is active throughout
+	r.Level, r.Activity = prevLevel, math.Min(1, math.Pow(10, (r.RMS-prevLevel)/10))
+	return r
+}
+
+// Reset discards the Meter state. This permits reusing a Meter rather than allocating a new one.
+func (m *Meter) Reset() {
+	m.p, m.q, m.sq, m.peak, m.n = 0, 0, 0, 0, 0
+	m.active = [thresholds]int64{}
+	m.hangover = [thresholds]int{}
+}
+
+// dbov converts a mean power per sample to dBov
+func dbov(power float64) float64 {
+	return 10 * math.Log10(power/(fullScale*fullScale))
+}
+
+// Measure returns the active speech level of data in the given format read from r
+func Measure(r io.Reader, format int) (Result, error) {
+	pr, err := pcm.NewReader(r, format)
+	if err != nil {
+		return Result{}, err
+	}
+	m := NewMeter()
+	s := make([]int16, 4096)
+	for {
+		n, err := pr.Read(s)
+		m.Add(s[:n])
+		if err == io.EOF {
+			return m.Result(), nil
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+}
+
+// Normalize writes data in the given format read from r to w, in the same format,
+// with its active speech level changed to target dBov. It returns the measurement
+// of the input. G711 data is rescaled through a gain table, LPCM data is clipped.
+func Normalize(w io.Writer, r io.Reader, format int, target float64) (Result, error) {
+	if !pcm.Valid(format) {
+		return Result{}, errors.New("invalid input format")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, err
+	}
+	m := NewMeter()
+	s := pcm.Decode(format, data, nil)
+	m.Add(s)
+	res := m.Result()
+	if math.IsInf(res.Level, -1) {
+		return res, errors.New("no active speech")
+	}
+	gain := target - res.Level
+	if format == g711.Lpcm {
+		k := math.Pow(10, gain/20)
+		for i, x := range s {
+			s[i] = clip(float64(x) * k)
+		}
+		data = pcm.Encode(format, s, data[:0])
+	} else {
+		t, err := g711.Gain(format, gain)
+		if err != nil {
+			return res, err
+		}
+		t.ApplyGain(data)
+	}
+	_, err = w.Write(data)
+	return res, err
+}
+
+func clip(x float64) int16 {
+	x = math.Round(x)
+	if x > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(x)
+}
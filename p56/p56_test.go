@@ -0,0 +1,92 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package p56
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+)
+
+// sine returns ms milliseconds of a 1kHz sine wave of amplitude a
+func sine(a float64, ms int) []int16 {
+	s := make([]int16, ms*8)
+	for i := range s {
+		s[i] = int16(math.Round(a * math.Sin(2*math.Pi*1000*float64(i)/8000)))
+	}
+	return s
+}
+
+// Test the level of a steady and of an interrupted tone
+func TestMeter(t *testing.T) {
+	a := fullScale / 10 // -23.01dBov RMS
+	want := 20*math.Log10(a/fullScale) - 3.01
+	m := NewMeter()
+	m.Add(sine(a, 4000))
+	r := m.Result()
+	if math.Abs(r.Level-want) > 0.1 || math.Abs(r.RMS-want) > 0.1 || r.Activity < 0.99 {
+		t.Errorf("Steady tone: %+v, expected level %.2f", r, want)
+	}
+	if math.Abs(r.Peak+20) > 0.01 {
+		t.Errorf("Steady tone peak: %.2f, expected -20", r.Peak)
+	}
+	m.Reset()
+	for i := 0; i < 4; i++ {
+		m.Add(sine(a, 1000))
+		m.Add(make([]int16, 8000))
+	}
+	r = m.Result()
+	// The hangover counts as active, which lowers the level of short bursts
+	if r.Level > want || r.Level < want-1.5 || r.Activity < 0.5 || r.Activity > 0.7 {
+		t.Errorf("Interrupted tone: %+v, expected level %.2f", r, want)
+	}
+	m.Reset()
+	if r = m.Result(); !math.IsInf(r.Level, -1) || r.Samples != 0 {
+		t.Errorf("No samples: %+v", r)
+	}
+}
+
+// Test the normalisation of speech in all formats
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		file   string
+		format int
+		tol    float64
+	}{
+		{"../testing/speech.raw", g711.Lpcm, 0.1},
+		{"../testing/speech.alaw", g711.Alaw, 0.3},
+		{"../testing/speech.ulaw", g711.Ulaw, 0.3},
+	} {
+		data, err := os.ReadFile(tc.file)
+		if err != nil {
+			t.Fatalf("Failed to read test data: %s\n", err)
+		}
+		in, err := Measure(bytes.NewReader(data), tc.format)
+		if err != nil {
+			t.Fatalf("%s: Measure failed: %s\n", tc.file, err)
+		}
+		if in.Activity <= 0.2 || in.Activity >= 1 || in.Level <= in.RMS || in.Peak <= in.Level {
+			t.Errorf("%s: implausible speech measurement: %+v", tc.file, in)
+		}
+		var b bytes.Buffer
+		if _, err := Normalize(&b, bytes.NewReader(data), tc.format, Target); err != nil {
+			t.Fatalf("%s: Normalize failed: %s\n", tc.file, err)
+		}
+		if b.Len() != len(data) {
+			t.Errorf("%s: normalised length %d, expected %d", tc.file, b.Len(), len(data))
+		}
+		out, _ := Measure(&b, tc.format)
+		if math.Abs(out.Level-Target) > tc.tol {
+			t.Errorf("%s: normalised level %.2f dBov, from %.2f dBov", tc.file, out.Level, in.Level)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package quality measures the transmission performance of the g711 codec the way
+ITU-T G.711 and G.712 define it.
+
+Calibrated sine and noise stimuli are passed through the g711 Encoder and Decoder
+and the signal to total distortion ratio and the gain tracking of the channel are
+checked against the G.712 template masks.
+*/
+package quality
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+
+	"github.com/zaf/g711"
+)
+
+// Stimulus is the type of test signal used for a measurement
+type Stimulus int
+
+const (
+	// Test signals
+	Sine  Stimulus = iota // Sine wave at 1020Hz, G.712 method 2
+	Noise                 // Gaussian noise, G.712 method 1
+)
+
+const (
+	sampleRate = 8000
+	toneFreq   = 1020  // Test tone frequency, not a sub-multiple of the sampling rate
+	samples    = 8000  // Stimulus length, an integer number of tone periods
+	refLevel   = -10.0 // Reference level for gain tracking in dBm0
+	seed       = 711   // Noise generator seed
+)
+
+func (s Stimulus) String() string {
+	switch s {
+	case Sine:
+		return "sine"
+	case Noise:
+		return "noise"
+	}
+	return "unknown"
+}
+
+// Result holds the outcome of a measurement at a single input level
+type Result struct {
+	Stimulus Stimulus // Test signal
+	Level    float64  // Input level in dBm0
+	SDR      float64  // Signal to total distortion ratio in dB
+	MinSDR   float64  // Lowest SDR allowed by the G.712 mask in dB
+	Gain     float64  // Gain relative to the -10dBm0 reference in dB, sine stimulus only
+	MaxGain  float64  // Largest gain variation allowed by the G.712 mask in dB, sine stimulus only
+	Pass     bool     // The measurement is within the masks
+}
+
+// point of a G.712 template mask, limits are interpolated linearly between points
+type point struct {
+	level float64
+	limit float64
+}
+
+var (
+	// Signal to total distortion ratio mask for the sinusoidal test signal
+	sineMask = []point{{-45, 24}, {-40, 29}, {-30, 35}, {0, 35}}
+	// Signal to total distortion ratio mask for the noise test signal
+	noiseMask = []point{{-55, 7.5}, {-40, 22.5}, {-34, 27.5}, {-6, 27.5}, {-3, 24.5}}
+	// Gain tracking mask, each limit applies to the levels up to its own
+	gainMask = []point{{-50, 3}, {-40, 1}, {3, 0.5}}
+)
+
+// Levels returns the input levels in dBm0 the G.712 masks are checked at for a stimulus
+func Levels(stimulus Stimulus) []float64 {
+	if stimulus == Noise {
+		return []float64{-55, -50, -45, -40, -34, -30, -27, -20, -10, -6, -3}
+	}
+	return []float64{-55, -50, -45, -40, -35, -30, -20, -10, 0, 3}
+}
+
+// Sweep measures the channel of the given law at every level returned by Levels
+func Sweep(law int, stimulus Stimulus) ([]Result, error) {
+	var results []Result
+	for _, level := range Levels(stimulus) {
+		r, err := Measure(law, stimulus, level)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// Measure encodes a stimulus at the given input level in dBm0 to A-law or u-law, decodes it
+// and compares the decoded signal with the G.712 masks.
+func Measure(law int, stimulus Stimulus, level float64) (Result, error) {
+	if law != g711.Alaw && law != g711.Ulaw {
+		return Result{}, errors.New("invalid input format")
+	}
+	if stimulus != Sine && stimulus != Noise {
+		return Result{}, errors.New("invalid stimulus")
+	}
+	r := Result{Stimulus: stimulus, Level: level}
+	in := generate(law, stimulus, level)
+	out, err := channel(law, in)
+	if err != nil {
+		return r, err
+	}
+	if stimulus == Sine {
+		amplitude, distortion := fitTone(out)
+		r.SDR = 10 * math.Log10(amplitude*amplitude/2/distortion)
+		ref, err := channel(law, generate(law, Sine, refLevel))
+		if err != nil {
+			return r, err
+		}
+		refAmplitude, _ := fitTone(ref)
+		r.Gain = 20*math.Log10(amplitude/toneAmplitude(law, level)) - 20*math.Log10(refAmplitude/toneAmplitude(law, refLevel))
+		r.MaxGain = gainLimit(level)
+		r.Pass = math.Abs(r.Gain) <= r.MaxGain
+		if level >= sineMask[0].level && level <= sineMask[len(sineMask)-1].level {
+			r.MinSDR = interpolate(sineMask, level)
+			r.Pass = r.Pass && r.SDR >= r.MinSDR
+		}
+		return r, nil
+	}
+	// The channel is digital and has neither delay nor gain,
+	// so everything other than the stimulus itself is distortion.
+	var signal, distortion float64
+	for i := range in {
+		signal += in[i] * in[i]
+		d := float64(out[i]) - in[i]
+		distortion += d * d
+	}
+	r.SDR = 10 * math.Log10(signal/distortion)
+	r.MinSDR = interpolate(noiseMask, level)
+	r.Pass = r.SDR >= r.MinSDR
+	return r, nil
+}
+
+// overload returns the amplitude of a 0dBm0 sine wave in 16bit LPCM.
+// The A-law overload point is at +3.14dBm0 and u-law at +3.17dBm0.
+func overload(law int) float64 {
+	if law == g711.Alaw {
+		return 32768 * math.Pow(10, -3.14/20)
+	}
+	return 32636 * math.Pow(10, -3.17/20)
+}
+
+// toneAmplitude returns the amplitude of a sine wave at level dBm0
+func toneAmplitude(law int, level float64) float64 {
+	return overload(law) * math.Pow(10, level/20)
+}
+
+// generate returns the stimulus at level dBm0 as unquantized samples
+func generate(law int, stimulus Stimulus, level float64) []float64 {
+	s := make([]float64, samples)
+	if stimulus == Sine {
+		a := toneAmplitude(law, level)
+		for i := range s {
+			s[i] = a * math.Sin(2*math.Pi*toneFreq*float64(i)/sampleRate+0.3)
+		}
+		return s
+	}
+	rms := toneAmplitude(law, level) / math.Sqrt2
+	rng := rand.New(rand.NewSource(seed))
+	for i := range s {
+		s[i] = math.Max(-32768, math.Min(32767, rms*rng.NormFloat64()))
+	}
+	return s
+}
+
+// channel passes samples through the g711 Encoder and Decoder
+func channel(law int, in []float64) ([]int16, error) {
+	lpcm := make([]byte, len(in)*2)
+	for i, x := range in {
+		v := int16(math.Round(x))
+		lpcm[2*i] = byte(v)
+		lpcm[2*i+1] = byte(v >> 8)
+	}
+	var err error
+	encoded := new(bytes.Buffer)
+	var encoder *g711.Encoder
+	var decoder *g711.Decoder
+	if law == g711.Alaw {
+		encoder, err = g711.NewAlawEncoder(encoded, g711.Lpcm)
+		if err == nil {
+			decoder, err = g711.NewAlawDecoder(encoded)
+		}
+	} else {
+		encoder, err = g711.NewUlawEncoder(encoded, g711.Lpcm)
+		if err == nil {
+			decoder, err = g711.NewUlawDecoder(encoded)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err = encoder.Write(lpcm); err != nil {
+		return nil, err
+	}
+	decoded, err := io.ReadAll(decoder)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int16, len(decoded)/2)
+	for i := range out {
+		out[i] = int16(decoded[2*i]) | int16(decoded[2*i+1])<<8
+	}
+	return out, nil
+}
+
+// fitTone fits a sine wave at the test frequency to the samples by least squares
+// and returns its amplitude and the mean power of the residual.
+func fitTone(s []int16) (amplitude, distortion float64) {
+	var a, b, c float64
+	for i, x := range s {
+		w := 2 * math.Pi * toneFreq * float64(i) / sampleRate
+		a += float64(x) * math.Cos(w)
+		b += float64(x) * math.Sin(w)
+		c += float64(x)
+	}
+	n := float64(len(s))
+	a, b, c = 2*a/n, 2*b/n, c/n
+	for i, x := range s {
+		w := 2 * math.Pi * toneFreq * float64(i) / sampleRate
+		d := float64(x) - a*math.Cos(w) - b*math.Sin(w) - c
+		distortion += d * d
+	}
+	return math.Hypot(a, b), distortion / n
+}
+
+// gainLimit returns the largest gain variation allowed at level
+func gainLimit(level float64) float64 {
+	for _, p := range gainMask {
+		if level <= p.level {
+			return p.limit
+		}
+	}
+	return gainMask[len(gainMask)-1].limit
+}
+
+// interpolate returns the limit of a mask at level
+func interpolate(mask []point, level float64) float64 {
+	if level <= mask[0].level {
+		return mask[0].limit
+	}
+	for i := 1; i < len(mask); i++ {
+		if level <= mask[i].level {
+			p, q := mask[i-1], mask[i]
+			return p.limit + (q.limit-p.limit)*(level-p.level)/(q.level-p.level)
+		}
+	}
+	return mask[len(mask)-1].limit
+}
@@ -0,0 +1,42 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package quality
+
+import (
+	"testing"
+
+	"github.com/zaf/g711"
+)
+
+// Test both laws against the G.712 masks
+func TestSweep(t *testing.T) {
+	for _, law := range []int{g711.Alaw, g711.Ulaw} {
+		for _, stimulus := range []Stimulus{Sine, Noise} {
+			results, err := Sweep(law, stimulus)
+			if err != nil {
+				t.Fatalf("Sweep failed: %s\n", err)
+			}
+			for _, r := range results {
+				if !r.Pass {
+					t.Errorf("law: %d, stimulus: %s, level: %.0f dBm0 is outside the G.712 mask: %+v", law, stimulus, r.Level, r)
+				}
+			}
+		}
+	}
+}
+
+// Test invalid arguments
+func TestMeasure(t *testing.T) {
+	if _, err := Measure(g711.Lpcm, Sine, 0); err == nil {
+		t.Error("Measure accepted LPCM as a law")
+	}
+	if _, err := Measure(g711.Alaw, Stimulus(2), 0); err == nil {
+		t.Error("Measure accepted an invalid stimulus")
+	}
+}
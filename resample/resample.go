@@ -0,0 +1,348 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package resample converts the sample rate of 16bit LPCM audio by arbitrary rational
+ratios, with a polyphase windowed sinc filter that also serves as the anti-aliasing
+and anti-imaging filter.
+
+A Resampler processes slices of samples and can wrap an io.Reader or io.Writer of
+16bit little endian LPCM data. The output is time aligned with the input.
+*/
+package resample
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	zeroCrossings = 32   // Filter zero crossings on each side, at the lower rate
+	cutoff        = 0.9  // Passband edge as a fraction of the lower Nyquist frequency
+	beta          = 7.0  // Kaiser window shape, about 70dB of stopband attenuation
+	maxFactor     = 1024 // Largest reduced interpolation or decimation factor
+)
+
+// Resampler converts the sample rate of a stream of samples
+type Resampler struct {
+	up, down int         // reduced interpolation and decimation factors
+	taps     int         // filter taps per phase
+	center   int64       // filter delay in interpolated samples
+	h        [][]float64 // polyphase filter, h[phase][tap]
+	channels int         // interleaved channels
+	hist     []float64   // input history, interleaved
+	partial  []int16     // samples of an incomplete input frame
+	first    int64       // input frame index of the start of hist
+	in       int64       // input frames received
+	m        int64       // index of the next output frame
+	out      []int16
+}
+
+// New returns a pointer to a Resampler from inRate to outRate samples per second
+func New(inRate, outRate int) (*Resampler, error) {
+	if inRate <= 0 || outRate <= 0 {
+		return nil, errors.New("invalid sample rate")
+	}
+	g := gcd(inRate, outRate)
+	up, down := outRate/g, inRate/g
+	if up > maxFactor || down > maxFactor {
+		return nil, errors.New("unsupported sample rate ratio")
+	}
+	r := &Resampler{up: up, down: down, channels: 1}
+	r.design()
+	r.Reset()
+	return r, nil
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// design computes the polyphase filter
+func (r *Resampler) design() {
+	factor := r.up
+	if r.down > factor {
+		factor = r.down
+	}
+	// Cutoff in cycles per interpolated sample
+	fc := cutoff * 0.5 / float64(factor)
+	r.taps = 2 * zeroCrossings * factor / r.up
+	length := r.taps * r.up
+	r.center = int64(length / 2)
+	c := float64(length / 2)
+	norm := bessel0(beta)
+	r.h = make([][]float64, r.up)
+	for p := range r.h {
+		r.h[p] = make([]float64, r.taps)
+		for k := range r.h[p] {
+			t := float64(p+k*r.up) - c
+			w := 0.0
+			if x := t / c; x*x < 1 {
+				w = bessel0(beta*math.Sqrt(1-x*x)) / norm
+			}
+			s := 2 * fc
+			if t != 0 {
+				s = math.Sin(2*math.Pi*fc*t) / (math.Pi * t)
+			}
+			r.h[p][k] = float64(r.up) * s * w
+		}
+	}
+}
+
+// bessel0 is the zeroth order modified Bessel function of the first kind
+func bessel0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		term *= (x / 2 / float64(k)) * (x / 2 / float64(k))
+		sum += term
+	}
+	return sum
+}
+
+// SetChannels sets the number of interleaved channels of the samples and resets the Resampler
+func (r *Resampler) SetChannels(n int) error {
+	if n < 1 {
+		return errors.New("invalid number of channels")
+	}
+	r.channels = n
+	r.Reset()
+	return nil
+}
+
+// Process resamples 16bit linear samples, interleaved if there are several channels,
+// and returns the output samples available. The returned slice is valid until the next call.
+func (r *Resampler) Process(s []int16) []int16 {
+	r.out = r.out[:0]
+	if len(r.partial) > 0 {
+		n := r.channels - len(r.partial)
+		if n > len(s) {
+			r.partial = append(r.partial, s...)
+			return r.out
+		}
+		r.partial = append(r.partial, s[:n]...)
+		s = s[n:]
+		r.add(r.partial)
+		r.partial = r.partial[:0]
+	}
+	whole := len(s) / r.channels * r.channels
+	r.add(s[:whole])
+	r.partial = append(r.partial, s[whole:]...)
+	r.produce(r.in)
+	return r.out
+}
+
+// add appends whole frames to the history
+func (r *Resampler) add(s []int16) {
+	for _, x := range s {
+		r.hist = append(r.hist, float64(x))
+	}
+	r.in += int64(len(s) / r.channels)
+}
+
+// Flush ends the stream and returns the output samples still held in the Resampler.
+// The returned slice is valid until the next call.
+func (r *Resampler) Flush() []int16 {
+	r.out = r.out[:0]
+	r.partial = r.partial[:0]
+	for i := 0; i < r.taps*r.channels; i++ {
+		r.hist = append(r.hist, 0)
+	}
+	r.produce(r.frames())
+	// Drop the output past the end of the input
+	total := (r.in*int64(r.up) + int64(r.down) - 1) / int64(r.down)
+	if extra := r.m - total; extra > 0 {
+		r.out = r.out[:int64(len(r.out))-extra*int64(r.channels)]
+		r.m = total
+	}
+	return r.out
+}
+
+// produce computes the output samples whose inputs are available up to index avail
+func (r *Resampler) produce(avail int64) {
+	for {
+		a := r.m*int64(r.down) + r.center
+		last := a / int64(r.up) // newest input of the output sample
+		if last >= avail {
+			break
+		}
+		h := r.h[a-last*int64(r.up)]
+		newest := int(last-r.first) * r.channels
+		for ch := 0; ch < r.channels; ch++ {
+			var y float64
+			for k, c := range h {
+				y += c * r.hist[newest+ch-k*r.channels]
+			}
+			r.out = append(r.out, clip(y))
+		}
+		r.m++
+	}
+	// Keep the history the next output frame needs
+	next := (r.m*int64(r.down)+r.center)/int64(r.up) - int64(r.taps) + 1
+	n := int64(len(r.hist) / r.channels)
+	if drop := next - r.first; drop > n/2 && drop > 0 {
+		if drop > n {
+			drop = n
+		}
+		r.hist = r.hist[:copy(r.hist, r.hist[drop*int64(r.channels):])]
+		r.first += drop
+	}
+}
+
+// frames returns the input frame index past the end of the history
+func (r *Resampler) frames() int64 {
+	return r.first + int64(len(r.hist)/r.channels)
+}
+
+// Reset discards the Resampler state. This permits reusing a Resampler rather than allocating a new one.
+func (r *Resampler) Reset() {
+	// The history starts with the zeros before the first sample
+	r.hist, r.partial = r.hist[:0], r.partial[:0]
+	for i := 0; i < r.taps*r.channels; i++ {
+		r.hist = append(r.hist, 0)
+	}
+	r.first, r.in, r.m = -int64(r.taps), 0, 0
+}
+
+func clip(x float64) int16 {
+	x = math.Round(x)
+	if x > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(x)
+}
+
+// Reader resamples 16bit little endian LPCM data read from an io.Reader
+type Reader struct {
+	r       *Resampler
+	source  io.Reader
+	buf     []byte
+	pending []byte  // input bytes of an incomplete sample
+	out     []int16 // resampled samples not yet read
+	eof     bool
+}
+
+// NewReader returns a pointer to a Reader that resamples the LPCM data read from r
+func NewReader(r io.Reader, rs *Resampler) (*Reader, error) {
+	if r == nil {
+		return nil, errors.New("io.Reader is nil")
+	}
+	if rs == nil {
+		return nil, errors.New("resampler is nil")
+	}
+	return &Reader{r: rs, source: r}, nil
+}
+
+// Read reads LPCM data from the source and resamples it. It returns
+// the number of bytes read and any error encountered.
+func (r *Reader) Read(p []byte) (int, error) {
+	n := len(p) / 2
+	if n == 0 {
+		return 0, nil
+	}
+	for len(r.out) < n && !r.eof {
+		// Enough input for the requested output
+		want := n*r.r.down/r.r.up*2 + 2
+		if cap(r.buf) < want {
+			r.buf = make([]byte, want)
+		}
+		m, err := r.source.Read(r.buf[:want])
+		data := append(r.pending, r.buf[:m]...)
+		whole := len(data) &^ 1
+		r.out = append(r.out, r.r.Process(decode(data[:whole]))...)
+		r.pending = append(r.pending[:0], data[whole:]...)
+		if err == io.EOF {
+			r.out = append(r.out, r.r.Flush()...)
+			r.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	if len(r.out) == 0 {
+		return 0, io.EOF
+	}
+	if n > len(r.out) {
+		n = len(r.out)
+	}
+	encode(r.out[:n], p)
+	r.out = r.out[:copy(r.out, r.out[n:])]
+	return 2 * n, nil
+}
+
+// Writer resamples 16bit little endian LPCM data written to an io.Writer
+type Writer struct {
+	r       *Resampler
+	dest    io.Writer
+	pending []byte // input bytes of an incomplete sample
+	buf     []byte
+}
+
+// NewWriter returns a pointer to a Writer that resamples the LPCM data written to it
+// and writes the result to w. Close flushes the samples held in the Resampler.
+func NewWriter(w io.Writer, rs *Resampler) (*Writer, error) {
+	if w == nil {
+		return nil, errors.New("io.Writer is nil")
+	}
+	if rs == nil {
+		return nil, errors.New("resampler is nil")
+	}
+	return &Writer{r: rs, dest: w}, nil
+}
+
+// Write resamples the LPCM data of p and writes the output available to the destination.
+// It returns the number of bytes consumed and any error encountered.
+func (w *Writer) Write(p []byte) (int, error) {
+	data := append(w.pending, p...)
+	whole := len(data) &^ 1
+	s := w.r.Process(decode(data[:whole]))
+	w.pending = append(w.pending[:0], data[whole:]...)
+	if err := w.write(s); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close writes the samples held in the Resampler to the destination
+func (w *Writer) Close() error {
+	return w.write(w.r.Flush())
+}
+
+func (w *Writer) write(s []int16) error {
+	if len(s) == 0 {
+		return nil
+	}
+	if cap(w.buf) < 2*len(s) {
+		w.buf = make([]byte, 2*len(s))
+	}
+	w.buf = w.buf[:2*len(s)]
+	encode(s, w.buf)
+	_, err := w.dest.Write(w.buf)
+	return err
+}
+
+// decode converts 16bit little endian LPCM data to samples
+func decode(data []byte) []int16 {
+	s := make([]int16, len(data)/2)
+	for i := range s {
+		s[i] = int16(data[2*i]) | int16(data[2*i+1])<<8
+	}
+	return s
+}
+
+// encode converts samples to 16bit little endian LPCM data
+func encode(s []int16, data []byte) {
+	for i, x := range s {
+		data[2*i], data[2*i+1] = byte(x), byte(x>>8)
+	}
+}
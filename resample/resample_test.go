@@ -0,0 +1,156 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package resample
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+// sine returns n samples of a sine wave of frequency f and amplitude a at the given rate
+func sine(f, a float64, rate, n int) []int16 {
+	s := make([]int16, n)
+	for i := range s {
+		s[i] = int16(math.Round(a * math.Sin(2*math.Pi*f*float64(i)/float64(rate))))
+	}
+	return s
+}
+
+// resample runs samples through a new Resampler in chunks of 100 samples
+func resample(t *testing.T, in, out int, s []int16) []int16 {
+	r, err := New(in, out)
+	if err != nil {
+		t.Fatalf("Failed to create Resampler: %s\n", err)
+	}
+	var y []int16
+	for len(s) > 0 {
+		n := 100
+		if n > len(s) {
+			n = len(s)
+		}
+		y = append(y, r.Process(s[:n])...)
+		s = s[n:]
+	}
+	return append(y, r.Flush()...)
+}
+
+// snr returns the SNR in dB of y against x, skipping the edges
+func snr(x, y []int16, edge int) float64 {
+	var sig, noise float64
+	for i := edge; i < len(x)-edge; i++ {
+		d := float64(y[i]) - float64(x[i])
+		sig += float64(x[i]) * float64(x[i])
+		noise += d * d
+	}
+	return 10 * math.Log10(sig/noise)
+}
+
+// Test that a tone keeps its frequency, level and timing across rates
+func TestRates(t *testing.T) {
+	const ms = 500
+	for _, tc := range []struct{ in, out int }{
+		{8000, 16000}, {16000, 8000}, {8000, 11025}, {44100, 8000}, {8000, 48000}, {22050, 8000},
+	} {
+		x := sine(1000, 10000, tc.in, tc.in*ms/1000)
+		y := resample(t, tc.in, tc.out, x)
+		if want := (len(x)*tc.out + tc.in - 1) / tc.in; len(y) != want {
+			t.Errorf("%d to %d: %d samples, expected %d", tc.in, tc.out, len(y), want)
+			continue
+		}
+		if s := snr(sine(1000, 10000, tc.out, len(y)), y, tc.out/100); s < 60 {
+			t.Errorf("%d to %d: SNR %.1f dB", tc.in, tc.out, s)
+		}
+	}
+	if _, err := New(8000, 0); err == nil {
+		t.Error("Invalid rate accepted")
+	}
+}
+
+// Test the rejection of frequencies above the output Nyquist frequency
+func TestAliasing(t *testing.T) {
+	y := resample(t, 16000, 8000, sine(5000, 10000, 16000, 16000))
+	var p float64
+	for _, v := range y[100 : len(y)-100] {
+		p += float64(v) * float64(v)
+	}
+	if l := 10 * math.Log10(p/float64(len(y)-200)/(10000*10000/2)); l > -60 {
+		t.Errorf("5kHz alias at %.1f dB", l)
+	}
+}
+
+// Test that the Reader and Writer match Process over odd chunks
+func TestReaderWriter(t *testing.T) {
+	x := sine(440, 8000, 8000, 4000)
+	want := make([]byte, 2*len(resample(t, 8000, 16000, x)))
+	encode(resample(t, 8000, 16000, x), want)
+	data := make([]byte, 2*len(x))
+	encode(x, data)
+
+	rs, _ := New(8000, 16000)
+	r, err := NewReader(bytes.NewReader(data), rs)
+	if err != nil {
+		t.Fatalf("Failed to create Reader: %s\n", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Reader output differs from Process: %v", err)
+	}
+
+	rs, _ = New(8000, 16000)
+	var b bytes.Buffer
+	w, err := NewWriter(&b, rs)
+	if err != nil {
+		t.Fatalf("Failed to create Writer: %s\n", err)
+	}
+	for chunk := data; len(chunk) > 0; {
+		n := 333
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+		w.Write(chunk[:n])
+		chunk = chunk[n:]
+	}
+	w.Close()
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Error("Writer output differs from Process")
+	}
+}
+
+// Test that interleaved channels are resampled independently
+func TestChannels(t *testing.T) {
+	left, right := sine(440, 8000, 8000, 3000), sine(1300, 5000, 8000, 3000)
+	stereo := make([]int16, 0, 2*len(left))
+	for i := range left {
+		stereo = append(stereo, left[i], right[i])
+	}
+	r, _ := New(8000, 11025)
+	if err := r.SetChannels(2); err != nil {
+		t.Fatalf("SetChannels failed: %s\n", err)
+	}
+	var y []int16
+	for i := 0; i < len(stereo); i += 101 {
+		end := i + 101
+		if end > len(stereo) {
+			end = len(stereo)
+		}
+		y = append(y, r.Process(stereo[i:end])...)
+	}
+	y = append(y, r.Flush()...)
+	l, rt := resample(t, 8000, 11025, left), resample(t, 8000, 11025, right)
+	if len(y) != 2*len(l) {
+		t.Fatalf("%d samples, expected %d", len(y), 2*len(l))
+	}
+	for i := range l {
+		if y[2*i] != l[i] || y[2*i+1] != rt[i] {
+			t.Fatalf("Frame %d: expected: %d %d, actual: %d %d", i, l[i], rt[i], y[2*i], y[2*i+1])
+		}
+	}
+}
@@ -0,0 +1,258 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package tty implements the TTY/TDD text telephone modem (TIA-825-A, ITU-T V.18 Baudot)
+over G711 or LPCM audio: 5 bit Baudot characters with LTRS/FIGS shifts, sent at
+45.45 or 50 baud over 1400Hz mark and 1800Hz space FSK.
+*/
+package tty
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/zaf/g711/generator"
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	markFreq  = 1400
+	spaceFreq = 1800
+	ltrs      = 0x1f // Shift to letters
+	figs      = 0x1b // Shift to figures
+	lead      = 150  // Mark before the first character in ms
+	hangover  = 300  // Mark after the last character in ms
+	reshift   = 72   // Characters between repeated shifts
+	minLevel  = -40  // Lowest level in dBm0
+	carrier   = 0.5  // Least fraction of the signal energy at the mark or space frequency
+)
+
+// Baudot characters in letters and figures shift, 0 marks unused codes
+var (
+	letters = [32]byte{
+		0, 'E', '\n', 'A', ' ', 'S', 'I', 'U', '\r', 'D', 'R', 'J', 'N', 'F', 'C', 'K',
+		'T', 'Z', 'L', 'W', 'H', 'Y', 'P', 'Q', 'O', 'B', 'G', 0, 'M', 'X', 'V', 0,
+	}
+	figures = [32]byte{
+		0, '3', '\n', '-', ' ', '\a', '8', '7', '\r', '$', '4', '\'', ',', '!', ':', '(',
+		'5', '"', ')', '2', '=', '6', '0', '1', '9', '?', '+', 0, '.', '/', ';', 0,
+	}
+)
+
+// Config holds the modem parameters
+type Config struct {
+	Baud  float64 // Bit rate, 45.45 or 50
+	Level float64 // Sending level in dBm0
+}
+
+var (
+	// DefaultConfig is the 45.45 baud TTY of TIA-825-A
+	DefaultConfig = Config{Baud: 45.45, Level: -13}
+	// Config50 is the 50 baud variant of ITU-T V.18
+	Config50 = Config{Baud: 50, Level: -13}
+)
+
+// code returns the Baudot code of a character and whether it is a figure
+func code(c byte) (uint8, bool, bool) {
+	for i := range letters {
+		if letters[i] == c && c != 0 {
+			return uint8(i), false, true
+		}
+	}
+	for i := range figures {
+		if figures[i] == c && c != 0 {
+			return uint8(i), true, true
+		}
+	}
+	return 0, false, false
+}
+
+// Baudot returns the Baudot codes of text, with the shifts needed to send it.
+// Letters are sent in upper case, new lines as CR LF and characters that
+// have no Baudot code are dropped.
+func Baudot(text string) []byte {
+	text = strings.ReplaceAll(strings.ToUpper(text), "\r\n", "\n")
+	var codes []byte
+	shifted, fig := false, false
+	sent := 0
+	for i := 0; i < len(text); i++ {
+		chars := []byte{text[i]}
+		if text[i] == '\n' {
+			chars = []byte{'\r', '\n'}
+		}
+		for _, c := range chars {
+			b, f, ok := code(c)
+			if !ok {
+				continue
+			}
+			// Space, CR and LF exist in both shifts
+			both := c == ' ' || c == '\r' || c == '\n'
+			if !shifted || (!both && f != fig) || sent >= reshift {
+				if !both {
+					fig = f
+				}
+				if fig {
+					codes = append(codes, figs)
+				} else {
+					codes = append(codes, ltrs)
+				}
+				shifted, sent = true, 0
+			}
+			codes = append(codes, b)
+			sent++
+		}
+	}
+	return codes
+}
+
+// Signal returns the TTY signal of text
+func Signal(text string, c Config) (generator.Signal, error) {
+	if c.Baud <= 0 {
+		return nil, errors.New("invalid bit rate")
+	}
+	codes := Baudot(text)
+	// Each bit is sent as two half bits, for the one and a half stop bits
+	half := func(n int, mark bool) []bool {
+		b := make([]bool, n)
+		for i := range b {
+			b[i] = mark
+		}
+		return b
+	}
+	bits := half(int(2*lead*c.Baud/1000), true)
+	for _, b := range codes {
+		for _, bit := range generator.UART([]byte{b}, 5, 0) {
+			bits = append(bits, bit, bit)
+		}
+		bits = append(bits, half(3, true)...)
+	}
+	bits = append(bits, half(int(2*hangover*c.Baud/1000), true)...)
+	return generator.FSK(markFreq, spaceFreq, 2*c.Baud, c.Level, bits), nil
+}
+
+// Encode writes the TTY signal of text to w in the given format
+func Encode(w io.Writer, text string, c Config, format int) error {
+	s, err := Signal(text, c)
+	if err != nil {
+		return err
+	}
+	r, err := generator.NewReader(s, format)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// Decoder receives TTY text from a stream of audio frames
+type Decoder struct {
+	format  int // input format
+	fsk     *dsp.FSK
+	uart    *dsp.UART
+	minFSK  float64 // signal energy in the FSK window at minLevel
+	hold    int     // samples of lost carrier before carrier drop
+	lost    int     // samples since the carrier was last present
+	carrier bool    // carrier detected
+	fig     bool    // figures shift
+	text    []byte  // text received in the current frame
+	samples []int16
+	fn      func(text string)
+}
+
+// NewDecoder returns a pointer to a Decoder for data in the given format that
+// calls fn with the text received in each frame
+func NewDecoder(format int, c Config, fn func(text string)) (*Decoder, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	if c.Baud <= 0 {
+		return nil, errors.New("invalid bit rate")
+	}
+	if fn == nil {
+		return nil, errors.New("callback is nil")
+	}
+	window := int(dsp.SampleRate / c.Baud)
+	a := dsp.Amplitude(minLevel)
+	return &Decoder{
+		format: format,
+		fsk:    dsp.NewFSK(markFreq, spaceFreq, window),
+		uart:   dsp.NewUART(c.Baud, 5),
+		minFSK: a * a / 2 * float64(window),
+		hold:   window,
+		fn:     fn,
+	}, nil
+}
+
+// Process decodes a frame of audio data
+func (d *Decoder) Process(frame []byte) {
+	d.samples = pcm.Decode(d.format, frame, d.samples[:0])
+	d.ProcessSamples(d.samples)
+}
+
+// ProcessSamples decodes 16bit linear samples
+func (d *Decoder) ProcessSamples(s []int16) {
+	d.text = d.text[:0]
+	for _, x := range s {
+		v := d.fsk.Demod(float64(x))
+		if d.fsk.Energy < d.minFSK || d.fsk.Carrier() < carrier {
+			if d.lost++; d.lost > d.hold && d.carrier {
+				d.carrier = false
+				d.uart.Reset()
+			}
+		} else {
+			d.lost = 0
+			if !d.carrier {
+				// Characters after a carrier loss start in letters shift
+				d.carrier, d.fig = true, false
+			}
+		}
+		if !d.carrier {
+			continue
+		}
+		if b, ok := d.uart.Sample(v > 0); ok {
+			d.char(b)
+		}
+	}
+	if len(d.text) > 0 {
+		d.fn(string(d.text))
+	}
+}
+
+// Carrier reports whether a TTY carrier is present
+func (d *Decoder) Carrier() bool {
+	return d.carrier
+}
+
+// Reset discards the Decoder state. This permits reusing a Decoder rather than allocating a new one.
+func (d *Decoder) Reset() {
+	d.fsk.Reset()
+	d.uart.Reset()
+	d.lost, d.carrier, d.fig = 0, false, false
+}
+
+// char adds a received Baudot code to the text
+func (d *Decoder) char(b byte) {
+	switch b {
+	case ltrs:
+		d.fig = false
+		return
+	case figs:
+		d.fig = true
+		return
+	}
+	c := letters[b]
+	if d.fig {
+		c = figures[b]
+	}
+	if c != 0 {
+		d.text = append(d.text, c)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package tty
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zaf/g711"
+)
+
+// decode runs a new Decoder over data in chunks of 160 bytes and returns the text
+func decode(t *testing.T, data []byte, format int, c Config) (string, *Decoder) {
+	var text strings.Builder
+	d, err := NewDecoder(format, c, func(s string) { text.WriteString(s) })
+	if err != nil {
+		t.Fatalf("Failed to create Decoder: %s\n", err)
+	}
+	for len(data) > 0 {
+		n := 160
+		if n > len(data) {
+			n = len(data)
+		}
+		d.Process(data[:n])
+		data = data[n:]
+	}
+	return text.String(), d
+}
+
+// Test the Baudot shifts
+func TestBaudot(t *testing.T) {
+	var tests = []struct {
+		text  string
+		codes []byte
+	}{
+		{"AB", []byte{ltrs, 0x03, 0x19}},
+		{"a 1", []byte{ltrs, 0x03, 0x04, figs, 0x17}},
+		{" 1", []byte{ltrs, 0x04, figs, 0x17}},
+		{"1 2\n", []byte{figs, 0x17, 0x04, 0x13, 0x08, 0x02}},
+		{"A~B", []byte{ltrs, 0x03, 0x19}},
+	}
+	for _, tc := range tests {
+		if codes := Baudot(tc.text); !bytes.Equal(codes, tc.codes) {
+			t.Errorf("%q: expected: % x, actual: % x", tc.text, tc.codes, codes)
+		}
+	}
+	codes := Baudot(strings.Repeat("E", 2*reshift))
+	if len(codes) != 2*reshift+2 || codes[reshift+1] != ltrs {
+		t.Errorf("Missing repeated shift: % x", codes)
+	}
+}
+
+// Test encoding and decoding in all formats at both bit rates
+func TestRoundTrip(t *testing.T) {
+	const text = "HELLO, TTY 123-456 (GA)?\n"
+	const want = "HELLO, TTY 123-456 (GA)?\r\n"
+	for _, c := range []Config{DefaultConfig, Config50} {
+		for _, format := range []int{g711.Lpcm, g711.Alaw, g711.Ulaw} {
+			var b bytes.Buffer
+			if err := Encode(&b, text, c, format); err != nil {
+				t.Fatalf("Encode failed: %s\n", err)
+			}
+			// Trailing silence drops the carrier
+			idle := pcmSilence(format, 4000)
+			got, d := decode(t, append(b.Bytes(), idle...), format, c)
+			if got != want {
+				t.Errorf("%g baud format %d: expected: %q, actual: %q", c.Baud, format, want, got)
+			}
+			if d.Carrier() {
+				t.Errorf("%g baud format %d: carrier still detected", c.Baud, format)
+			}
+		}
+	}
+}
+
+// Test decoding at low level and that speech produces no text
+func TestDecode(t *testing.T) {
+	weak := DefaultConfig
+	weak.Level = -35
+	var b bytes.Buffer
+	if err := Encode(&b, "WEAK SIGNAL", weak, g711.Alaw); err != nil {
+		t.Fatalf("Encode failed: %s\n", err)
+	}
+	if got, _ := decode(t, b.Bytes(), g711.Alaw, DefaultConfig); got != "WEAK SIGNAL" {
+		t.Errorf("-35dBm0: expected: WEAK SIGNAL, actual: %q", got)
+	}
+	data, err := os.ReadFile("../testing/speech.ulaw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	if got, _ := decode(t, data, g711.Ulaw, DefaultConfig); got != "" {
+		t.Errorf("False text from speech: %q", got)
+	}
+	if _, err := NewDecoder(g711.Alaw, DefaultConfig, nil); err == nil {
+		t.Error("Nil callback accepted")
+	}
+}
+
+// pcmSilence returns n samples of silence in the given format
+func pcmSilence(format, n int) []byte {
+	switch format {
+	case g711.Alaw:
+		return bytes.Repeat([]byte{g711.EncodeAlawFrame(0)}, n)
+	case g711.Ulaw:
+		return bytes.Repeat([]byte{g711.EncodeUlawFrame(0)}, n)
+	}
+	return make([]byte, 2*n)
+}
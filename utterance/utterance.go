@@ -0,0 +1,248 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+/*
+Package utterance splits G711 or LPCM audio into utterances for speech
+recognition.
+
+Speech is told from background noise by the level of 10ms blocks against a
+noise floor, the quietest block of the last three seconds. An utterance starts
+with a burst of speech and ends after a silence, both padded with some of the
+surrounding audio, and utterances that run too long are cut. Each utterance
+is passed to a callback with its sample offsets and its data in the input
+format, unchanged.
+*/
+package utterance
+
+import (
+	"errors"
+	"io"
+
+	"github.com/zaf/g711/internal/dsp"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+const (
+	blockSize   = 80 // Samples per level measurement, 10ms
+	ms          = dsp.SampleRate / 1000
+	minFloor    = -60 // Lowest noise floor in dBm0
+	floorBlocks = 300 // Blocks of the noise floor history, 3s
+)
+
+// Config holds the segmentation parameters, durations in ms
+type Config struct {
+	MinSilence int     // Silence that ends an utterance
+	MinSpeech  int     // Speech that starts an utterance
+	Padding    int     // Audio kept before and after the speech of an utterance
+	MaxLength  int     // Longest utterance, longer ones are cut
+	Threshold  float64 // Level of speech above the noise floor in dB
+}
+
+// DefaultConfig suits speech recognition of conversational speech
+var DefaultConfig = Config{
+	MinSilence: 500,
+	MinSpeech:  100,
+	Padding:    200,
+	MaxLength:  15000,
+	Threshold:  12,
+}
+
+// Utterance is a segment of speech
+type Utterance struct {
+	Start int64  // Sample offset of the start of the utterance
+	End   int64  // Sample offset of the end of the utterance
+	Data  []byte // Audio of the utterance in the input format, valid until the callback returns
+}
+
+// Segmenter finds utterances in a stream of audio frames
+type Segmenter struct {
+	format    int // input format
+	size      int // bytes per sample
+	config    Config
+	emit      func(Utterance) error
+	buf       []byte // input data from bufStart on
+	bufStart  int64  // sample offset of the start of buf
+	samples   []int16
+	pos       int       // samples of the current block
+	acc       float64   // sum of squares of the current block
+	offset    int64     // sample offset of the end of the last block
+	levels    []float64 // block levels of the noise floor history, in dBm0
+	run       int64     // start of the current run of speech blocks, -1 without speech
+	active    bool      // an utterance is in progress
+	start     int64     // start of the utterance in progress
+	lastVoice int64     // end of the last speech block of the utterance
+	prevEnd   int64     // end of the last utterance
+	err       error
+}
+
+// New returns a pointer to a Segmenter of data in the given format that calls
+// emit for every utterance, in order. An error returned by emit stops the segmentation.
+func New(format int, c Config, emit func(Utterance) error) (*Segmenter, error) {
+	if !pcm.Valid(format) {
+		return nil, errors.New("invalid input format")
+	}
+	if emit == nil {
+		return nil, errors.New("callback is nil")
+	}
+	if c.MinSilence <= 0 || c.MinSpeech <= 0 || c.Padding < 0 || c.MaxLength < c.MinSpeech+2*c.Padding {
+		return nil, errors.New("invalid configuration")
+	}
+	s := &Segmenter{format: format, size: pcm.Size(format), config: c, emit: emit}
+	s.Reset()
+	return s, nil
+}
+
+// Split reads data in the given format from r until EOF and calls emit for every utterance
+func Split(r io.Reader, format int, c Config, emit func(Utterance) error) error {
+	s, err := New(format, c, emit)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(s, r); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+// Write segments the contents of p. It returns the number of bytes consumed
+// and the first error returned by the callback.
+func (s *Segmenter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.buf = append(s.buf, p...)
+	// Whole samples not yet measured
+	from := int(s.offset-s.bufStart)*s.size + s.pos*s.size
+	whole := (len(s.buf) - from) / s.size * s.size
+	s.samples = pcm.Decode(s.format, s.buf[from:from+whole], s.samples[:0])
+	for _, x := range s.samples {
+		s.acc += float64(x) * float64(x)
+		if s.pos++; s.pos == blockSize {
+			s.block()
+			if s.err != nil {
+				return 0, s.err
+			}
+		}
+	}
+	s.trim()
+	return len(p), nil
+}
+
+// Flush ends the stream and emits the utterance in progress
+func (s *Segmenter) Flush() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.active {
+		end := s.lastVoice + int64(s.config.Padding*ms)
+		if total := s.bufStart + int64(len(s.buf)/s.size); end > total {
+			end = total
+		}
+		s.cut(end)
+	}
+	return s.err
+}
+
+// Reset discards the Segmenter state. This permits reusing a Segmenter rather than allocating a new one.
+func (s *Segmenter) Reset() {
+	s.buf, s.bufStart = s.buf[:0], 0
+	s.pos, s.acc, s.offset = 0, 0, 0
+	s.levels = s.levels[:0]
+	s.run, s.active, s.prevEnd, s.err = -1, false, 0, nil
+}
+
+// block classifies the last block as speech or noise and updates the utterances
+func (s *Segmenter) block() {
+	level := dsp.Level(s.acc / blockSize)
+	s.acc, s.pos = 0, 0
+	start := s.offset
+	s.offset += blockSize
+	c := s.config
+	if len(s.levels) < floorBlocks {
+		s.levels = append(s.levels, level)
+	} else {
+		s.levels[(start/blockSize)%floorBlocks] = level
+	}
+	floor := float64(minFloor)
+	if min := minimum(s.levels); min > floor {
+		floor = min
+	}
+	speech := level > floor+c.Threshold
+	if speech {
+		if s.run < 0 {
+			s.run = start
+		}
+	} else {
+		s.run = -1
+	}
+	pad := int64(c.Padding * ms)
+	if s.active {
+		if speech {
+			s.lastVoice = s.offset
+		}
+		switch {
+		case s.offset-s.lastVoice >= int64(c.MinSilence*ms) && s.offset-s.lastVoice >= pad:
+			s.cut(s.lastVoice + pad)
+		case s.offset-s.start >= int64(c.MaxLength*ms):
+			// Cut a long utterance and carry on with the next one
+			end := s.offset
+			if s.lastVoice+pad < end {
+				end = s.lastVoice + pad
+			}
+			s.cut(end)
+			if s.err == nil && speech {
+				s.active, s.start = true, s.offset
+			}
+		}
+		return
+	}
+	if s.run >= 0 && s.offset-s.run >= int64(c.MinSpeech*ms) {
+		s.active, s.lastVoice = true, s.offset
+		s.start = s.run - pad
+		if s.start < s.prevEnd {
+			s.start = s.prevEnd
+		}
+	}
+}
+
+// cut emits the utterance in progress up to end
+func (s *Segmenter) cut(end int64) {
+	s.active = false
+	s.err = s.emit(Utterance{
+		Start: s.start,
+		End:   end,
+		Data:  s.buf[int(s.start-s.bufStart)*s.size : int(end-s.bufStart)*s.size],
+	})
+	s.prevEnd = end
+}
+
+// trim discards the buffered data that no utterance can include
+func (s *Segmenter) trim() {
+	keep := s.offset - int64((s.config.Padding+s.config.MinSpeech)*ms)
+	if s.active {
+		keep = s.start
+	}
+	if keep < s.prevEnd {
+		keep = s.prevEnd
+	}
+	if n := int(keep-s.bufStart) * s.size; n > 0 {
+		s.buf = append(s.buf[:0], s.buf[n:]...)
+		s.bufStart = keep
+	}
+}
+
+// minimum returns the lowest of the values
+func minimum(v []float64) float64 {
+	m := v[0]
+	for _, x := range v[1:] {
+		if x < m {
+			m = x
+		}
+	}
+	return m
+}
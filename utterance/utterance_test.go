@@ -0,0 +1,165 @@
+/*
+	Copyright (C) 2016 - 2024, Lefteris Zafiris <zaf@fastmail.com>
+
+	This program is free software, distributed under the terms of
+	the BSD 3-Clause License. See the LICENSE file
+	at the top of the source tree.
+*/
+
+package utterance
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/zaf/g711"
+	"github.com/zaf/g711/generator"
+	"github.com/zaf/g711/internal/pcm"
+)
+
+// voice returns ms milliseconds of noise at a speech level
+func voice(ms int) generator.Signal {
+	return generator.WhiteNoise(-20, ms, int64(ms))
+}
+
+// render returns the samples of a signal
+func render(t *testing.T, s generator.Signal) []int16 {
+	r, _ := generator.NewReader(s, g711.Lpcm)
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Reading failed: %s\n", err)
+	}
+	return pcm.Decode(g711.Lpcm, data, nil)
+}
+
+// segment runs a new Segmenter over data in chunks of 160 bytes and returns
+// the utterances with a copy of their data
+func segment(t *testing.T, c Config, data []byte, format int) []Utterance {
+	var utterances []Utterance
+	s, err := New(format, c, func(u Utterance) error {
+		u.Data = append([]byte(nil), u.Data...)
+		utterances = append(utterances, u)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to create Segmenter: %s\n", err)
+	}
+	for len(data) > 0 {
+		n := 160
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := s.Write(data[:n]); err != nil {
+			t.Fatalf("Segmentation failed: %s\n", err)
+		}
+		data = data[n:]
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Segmentation failed: %s\n", err)
+	}
+	return utterances
+}
+
+// Test the utterance offsets and data
+func TestSegmenter(t *testing.T) {
+	short := DefaultConfig
+	short.MaxLength = 1000
+	var tests = []struct {
+		name   string
+		config Config
+		signal generator.Signal
+		ms     [][2]int64 // expected start and end of the utterances in ms
+	}{
+		{
+			"pauses", DefaultConfig,
+			generator.Sequence(generator.Silence(1000), voice(800), generator.Silence(1000), voice(600),
+				generator.Silence(300), voice(400), generator.Silence(1000)),
+			[][2]int64{{800, 2000}, {2600, 4300}},
+		},
+		{
+			"max length", short,
+			generator.Sequence(generator.Silence(500), voice(2500), generator.Silence(1000)),
+			[][2]int64{{300, 1300}, {1300, 2300}, {2300, 3200}},
+		},
+		{
+			"unfinished", DefaultConfig,
+			generator.Sequence(generator.Silence(100), voice(500), generator.Silence(100)),
+			[][2]int64{{0, 700}},
+		},
+		{"silence", DefaultConfig, generator.Silence(2000), nil},
+		{"click", DefaultConfig, generator.Sequence(generator.Silence(500), voice(50), generator.Silence(1000)), nil},
+	}
+	for _, tc := range tests {
+		samples := render(t, tc.signal)
+		for _, format := range []int{g711.Alaw, g711.Ulaw, g711.Lpcm} {
+			data := pcm.Encode(format, samples, nil)
+			size := int64(pcm.Size(format))
+			utterances := segment(t, tc.config, data, format)
+			if len(utterances) != len(tc.ms) {
+				t.Errorf("%s format %d: %d utterances, expected %d", tc.name, format, len(utterances), len(tc.ms))
+				continue
+			}
+			for i, u := range utterances {
+				if u.Start != tc.ms[i][0]*8 || u.End != tc.ms[i][1]*8 {
+					t.Errorf("%s format %d: utterance %d at %d-%d, expected %d-%d",
+						tc.name, format, i, u.Start, u.End, tc.ms[i][0]*8, tc.ms[i][1]*8)
+				}
+				if !bytes.Equal(u.Data, data[u.Start*size:u.End*size]) {
+					t.Errorf("%s format %d: utterance %d data mismatch", tc.name, format, i)
+				}
+			}
+		}
+	}
+	if _, err := New(g711.Alaw, Config{}, func(Utterance) error { return nil }); err == nil {
+		t.Error("Invalid configuration accepted")
+	}
+	if _, err := New(g711.Alaw, DefaultConfig, nil); err == nil {
+		t.Error("Nil callback accepted")
+	}
+}
+
+// Test the segmentation of recorded speech
+func TestSpeech(t *testing.T) {
+	data, err := os.ReadFile("../testing/speech.raw")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %s\n", err)
+	}
+	c := DefaultConfig
+	c.MaxLength = 2000
+	var utterances []Utterance
+	err = Split(bytes.NewReader(data), g711.Lpcm, c, func(u Utterance) error {
+		utterances = append(utterances, Utterance{Start: u.Start, End: u.End})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Segmentation failed: %s\n", err)
+	}
+	if len(utterances) == 0 {
+		t.Fatal("No utterances found in speech")
+	}
+	var prev int64
+	for i, u := range utterances {
+		if u.Start < prev || u.End <= u.Start || u.End > int64(len(data)/2) || u.End-u.Start > int64(c.MaxLength*8) {
+			t.Errorf("Utterance %d at %d-%d after %d", i, u.Start, u.End, prev)
+		}
+		prev = u.End
+	}
+}
+
+// Test that a callback error stops the segmentation
+func TestCallbackError(t *testing.T) {
+	samples := render(t, generator.Sequence(generator.Silence(500), voice(500), generator.Silence(1000), voice(500), generator.Silence(1000)))
+	data := pcm.Encode(g711.Ulaw, samples, nil)
+	stop := errors.New("stop")
+	calls := 0
+	err := Split(bytes.NewReader(data), g711.Ulaw, DefaultConfig, func(u Utterance) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Split returned %v after %d calls, expected %v after 1", err, calls, stop)
+	}
+}